@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+
+	"github.com/mattikus/gobot/internal/modules"
+)
+
+// Config is everything needed to run the bot. It's read from a YAML file, and any setting can then
+// be overridden with the environment variable named in its comment.
+type Config struct {
+	// Name is what the bot calls itself. BOT_NAME.
+	Name string `yaml:"name"`
+	// UI is "slack" to connect to Slack, "discord" to connect to Discord, "irc" to connect to an IRC
+	// server, or "cli" to talk to the bot in the terminal while developing modules. BOT_UI, or the
+	// -ui flag.
+	UI string `yaml:"ui"`
+	// Slack configures how the bot connects to Slack.
+	Slack SlackConfig `yaml:"slack"`
+	// Discord configures how the bot connects to Discord.
+	Discord DiscordConfig `yaml:"discord"`
+	// IRC configures how the bot connects to an IRC server.
+	IRC IRCConfig `yaml:"irc"`
+	// Log configures what the bot logs and how.
+	Log LogConfig `yaml:"log"`
+	// Metrics configures where the bot's metrics are served.
+	Metrics MetricsConfig `yaml:"metrics"`
+	// StorePath is where module state is kept, or in memory if unset. STORE_PATH.
+	StorePath string `yaml:"store_path"`
+	// RandSeed makes the bot's random outcomes reproducible when set. RAND_SEED.
+	RandSeed *int64 `yaml:"rand_seed"`
+	// Owners are the user IDs of the bot's owners, who can use every command and give other users
+	// roles, including making them admins. On IRC they're services account names, and users who
+	// aren't logged in are never given a role. BOT_OWNERS, as a comma separated list.
+	Owners []string `yaml:"owners"`
+	// Modules enables, disables, limits and configures modules.
+	Modules modules.Settings `yaml:"modules"`
+}
+
+// SlackConfig configures how the bot connects to Slack.
+type SlackConfig struct {
+	// Mode is "events" to serve the Events API over HTTP, or "socket" to use Socket Mode.
+	// SLACK_MODE.
+	Mode string `yaml:"mode"`
+	// Token is the bot token. API_TOKEN.
+	Token string `yaml:"token"`
+	// AppToken is the app-level token used by Socket Mode. APP_TOKEN.
+	AppToken string `yaml:"app_token"`
+	// SigningSecret verifies requests from Slack in events mode. SIGNING_SECRET.
+	SigningSecret string `yaml:"signing_secret"`
+	// Listen is the address the events mode HTTP server listens on. LISTEN_ADDR, or PORT for just
+	// the port.
+	Listen string `yaml:"listen"`
+}
+
+// DiscordConfig configures how the bot connects to Discord.
+type DiscordConfig struct {
+	// Token is the bot token. DISCORD_TOKEN.
+	Token string `yaml:"token"`
+}
+
+// IRCConfig configures how the bot connects to an IRC server.
+type IRCConfig struct {
+	// Server is the host and port of the server, as in "irc.libera.chat:6697". IRC_SERVER.
+	Server string `yaml:"server"`
+	// TLS connects to the server over TLS, which it does unless disabled. IRC_TLS.
+	TLS bool `yaml:"tls"`
+	// Nick is the bot's nick, or its name if unset. IRC_NICK.
+	Nick string `yaml:"nick"`
+	// SASLUser and SASLPassword authenticate the bot with SASL while connecting. IRC_SASL_USER and
+	// IRC_SASL_PASSWORD.
+	SASLUser     string `yaml:"sasl_user"`
+	SASLPassword string `yaml:"sasl_password"`
+	// NickServPassword identifies the bot with NickServ once connected. NICKSERV_PASSWORD.
+	NickServPassword string `yaml:"nickserv_password"`
+	// Channels are joined once connected. IRC_CHANNELS, as a comma separated list.
+	Channels []string `yaml:"channels"`
+}
+
+// LogConfig configures what the bot logs and how.
+type LogConfig struct {
+	// Level is the least severe level logged, such as "info" or "debug". It defaults to "info", or
+	// "warn" for the cli, which shares the terminal with the log. LOG_LEVEL.
+	Level string `yaml:"level"`
+	// Format is "json" or "text". LOG_FORMAT.
+	Format string `yaml:"format"`
+}
+
+// MetricsConfig configures where the bot's metrics are served.
+type MetricsConfig struct {
+	// Listen is the address an HTTP server listens on to serve metrics, such as how many requests
+	// were rate limited, with expvar at /debug/vars. Metrics aren't served if unset, whichever UI
+	// is used. METRICS_ADDR.
+	Listen string `yaml:"listen"`
+}
+
+// defaultConfig returns the settings used for anything not configured.
+func defaultConfig() Config {
+	return Config{
+		Name:  "gobot",
+		UI:    "slack",
+		Slack: SlackConfig{Mode: "events", Listen: ":8080"},
+		IRC:   IRCConfig{TLS: true},
+		Log:   LogConfig{Format: "json"},
+	}
+}
+
+// loadConfig reads the config file at path, if any, on top of the defaults and then applies any
+// overrides from the environment, looked up with getenv.
+func loadConfig(path string, getenv func(string) string) (Config, error) {
+	cfg := defaultConfig()
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("%v: %w", path, err)
+		}
+	}
+
+	strs := map[string]*string{
+		"BOT_NAME":          &cfg.Name,
+		"BOT_UI":            &cfg.UI,
+		"SLACK_MODE":        &cfg.Slack.Mode,
+		"API_TOKEN":         &cfg.Slack.Token,
+		"APP_TOKEN":         &cfg.Slack.AppToken,
+		"SIGNING_SECRET":    &cfg.Slack.SigningSecret,
+		"LISTEN_ADDR":       &cfg.Slack.Listen,
+		"DISCORD_TOKEN":     &cfg.Discord.Token,
+		"IRC_SERVER":        &cfg.IRC.Server,
+		"IRC_NICK":          &cfg.IRC.Nick,
+		"IRC_SASL_USER":     &cfg.IRC.SASLUser,
+		"IRC_SASL_PASSWORD": &cfg.IRC.SASLPassword,
+		"NICKSERV_PASSWORD": &cfg.IRC.NickServPassword,
+		"LOG_LEVEL":         &cfg.Log.Level,
+		"LOG_FORMAT":        &cfg.Log.Format,
+		"METRICS_ADDR":      &cfg.Metrics.Listen,
+		"STORE_PATH":        &cfg.StorePath,
+	}
+	for name, field := range strs {
+		if v := getenv(name); v != "" {
+			*field = v
+		}
+	}
+	if port := getenv("PORT"); port != "" && getenv("LISTEN_ADDR") == "" {
+		cfg.Slack.Listen = ":" + port
+	}
+	if s := getenv("RAND_SEED"); s != "" {
+		seed, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("RAND_SEED: invalid seed %q", s)
+		}
+		cfg.RandSeed = &seed
+	}
+	if s := getenv("IRC_TLS"); s != "" {
+		useTLS, err := strconv.ParseBool(s)
+		if err != nil {
+			return cfg, fmt.Errorf("IRC_TLS: invalid boolean %q", s)
+		}
+		cfg.IRC.TLS = useTLS
+	}
+	if owners := getenv("BOT_OWNERS"); owners != "" {
+		cfg.Owners = splitList(owners)
+	}
+	if channels := getenv("IRC_CHANNELS"); channels != "" {
+		cfg.IRC.Channels = splitList(channels)
+	}
+	if cfg.Log.Level == "" {
+		cfg.Log.Level = "info"
+		if cfg.UI == "cli" {
+			cfg.Log.Level = "warn"
+		}
+	}
+	return cfg, cfg.validate()
+}
+
+// splitList splits a comma separated list, dropping any blank items.
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// validate checks the config can be used to run the bot, naming the setting at fault if not.
+func (cfg Config) validate() error {
+	if cfg.Name == "" {
+		return fmt.Errorf("name: must not be empty")
+	}
+	switch cfg.UI {
+	case "slack":
+		if err := cfg.Slack.validate(); err != nil {
+			return err
+		}
+	case "discord":
+		if cfg.Discord.Token == "" {
+			return fmt.Errorf("discord.token: must be set")
+		}
+	case "irc":
+		if err := cfg.IRC.validate(); err != nil {
+			return err
+		}
+	case "cli":
+	default:
+		return fmt.Errorf("ui: unknown UI %q, must be one of: slack, discord, irc, cli", cfg.UI)
+	}
+	if _, err := logrus.ParseLevel(cfg.Log.Level); err != nil {
+		return fmt.Errorf("log.level: %w", err)
+	}
+	if cfg.Log.Format != "json" && cfg.Log.Format != "text" {
+		return fmt.Errorf("log.format: unknown format %q, must be one of: json, text", cfg.Log.Format)
+	}
+	if err := cfg.Modules.Validate(); err != nil {
+		return fmt.Errorf("modules.%w", err)
+	}
+	return nil
+}
+
+// validate checks the bot can connect to Slack.
+func (cfg SlackConfig) validate() error {
+	if cfg.Token == "" {
+		return fmt.Errorf("slack.token: must be set")
+	}
+	switch cfg.Mode {
+	case "events":
+		if cfg.SigningSecret == "" {
+			return fmt.Errorf("slack.signing_secret: must be set in events mode")
+		}
+		if cfg.Listen == "" {
+			return fmt.Errorf("slack.listen: must be set in events mode")
+		}
+	case "socket":
+		if cfg.AppToken == "" {
+			return fmt.Errorf("slack.app_token: must be set in socket mode")
+		}
+	default:
+		return fmt.Errorf("slack.mode: unknown mode %q, must be one of: events, socket", cfg.Mode)
+	}
+	return nil
+}
+
+// validate checks the bot can connect to an IRC server.
+func (cfg IRCConfig) validate() error {
+	if cfg.Server == "" {
+		return fmt.Errorf("irc.server: must be set")
+	}
+	if (cfg.SASLUser == "") != (cfg.SASLPassword == "") {
+		return fmt.Errorf("irc.sasl_user: must be set along with irc.sasl_password")
+	}
+	return nil
+}
+
+// configureLog applies the log settings to logger.
+func (cfg Config) configureLog(logger *logrus.Logger) {
+	if level, err := logrus.ParseLevel(cfg.Log.Level); err == nil {
+		logger.SetLevel(level)
+	}
+	if cfg.Log.Format == "text" {
+		logger.SetFormatter(&logrus.TextFormatter{})
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(name string) string { return vars[name] }
+	}
+
+	cfg, err := loadConfig("../../gobot.example.yaml", env(nil))
+	if err != nil {
+		t.Fatalf("unable to load example config: %v", err)
+	}
+	if cfg.Slack.Mode != "events" || cfg.Slack.Listen != ":8080" || cfg.StorePath != "gobot.db" {
+		t.Errorf("unexpected slack or store settings: %+v, %q", cfg.Slack, cfg.StorePath)
+	}
+	if cfg.Modules.Channels["C0123456789"]["cards"] || cfg.Modules.RateLimits.Intents["emoji.spin"].Every != 30*time.Second {
+		t.Errorf("unexpected module settings: %+v", cfg.Modules)
+	}
+
+	cfg, err = loadConfig("../../gobot.example.yaml", env(map[string]string{
+		"SLACK_MODE":   "socket",
+		"APP_TOKEN":    "xapp-env",
+		"PORT":         "9000",
+		"BOT_OWNERS":   "UA, UB",
+		"RAND_SEED":    "7",
+		"METRICS_ADDR": ":9090",
+	}))
+	if err != nil {
+		t.Fatalf("unable to load config with overrides: %v", err)
+	}
+	if cfg.Slack.Mode != "socket" || cfg.Slack.AppToken != "xapp-env" || cfg.Slack.Listen != ":9000" {
+		t.Errorf("environment didn't override slack settings: %+v", cfg.Slack)
+	}
+	if len(cfg.Owners) != 2 || cfg.Owners[1] != "UB" || cfg.RandSeed == nil || *cfg.RandSeed != 7 {
+		t.Errorf("environment didn't override owners and seed: %v, %v", cfg.Owners, cfg.RandSeed)
+	}
+	if cfg.Metrics.Listen != ":9090" {
+		t.Errorf("environment didn't override metrics settings: %+v", cfg.Metrics)
+	}
+
+	// Nothing needs to be configured to talk to the bot in the terminal, where only warnings are
+	// logged unless another level is asked for.
+	if cfg, err := loadConfig("", env(map[string]string{"BOT_UI": "cli"})); err != nil || cfg.UI != "cli" || cfg.Log.Level != "warn" {
+		t.Errorf("unable to load config for the cli: %+v, %v", cfg, err)
+	}
+	if cfg, err := loadConfig("", env(map[string]string{"BOT_UI": "cli", "LOG_LEVEL": "info"})); err != nil || cfg.Log.Level != "info" {
+		t.Errorf("cli overrode the configured log level: %+v, %v", cfg.Log, err)
+	}
+	cfg, err = loadConfig("", env(map[string]string{"BOT_UI": "discord", "DISCORD_TOKEN": "discord-env"}))
+	if err != nil || cfg.Discord.Token != "discord-env" {
+		t.Errorf("unable to load config for discord: %+v, %v", cfg.Discord, err)
+	}
+	cfg, err = loadConfig("", env(map[string]string{
+		"BOT_UI":       "irc",
+		"IRC_SERVER":   "irc.test:6667",
+		"IRC_TLS":      "false",
+		"IRC_CHANNELS": "#a, #b",
+	}))
+	if err != nil || cfg.IRC.Server != "irc.test:6667" || cfg.IRC.TLS || len(cfg.IRC.Channels) != 2 || cfg.IRC.Channels[1] != "#b" {
+		t.Errorf("unable to load config for irc: %+v, %v", cfg.IRC, err)
+	}
+
+	tests := []struct {
+		config, want string
+	}{
+		{"slack: {token: x, signing_secret: y}\nlog: {level: loud}", "log.level: "},
+		{"slack: {token: x, mode: rtm}", `slack.mode: unknown mode "rtm"`},
+		{"slack: {token: x, mode: socket}", "slack.app_token: must be set"},
+		{"slack: {token: x}", "slack.signing_secret: must be set"},
+		{"slack: {token: x, signing_secret: y, colour: red}", "field colour not found"},
+		{"slack: {token: x, signing_secret: y}\nmodules: {channels: {C1: {nope: true}}}", `modules.channels.C1: unknown module "nope"`},
+		{"slack: {token: x, signing_secret: y}\nmodules: {options: {cah: {reveal_delay: soon}}}", "modules.options.cah.reveal_delay: "},
+		{"slack: {token: x, signing_secret: y}\nmodules: {options: {cah: {hand_size: 3}}}", `modules.options.cah: unknown setting "hand_size"`},
+		{"slack: {token: x, signing_secret: y}\nmodules: {rate_limits: {user: {burst: 1, every: often}}}", `invalid rate limit interval "often"`},
+		{"name: gobot", "slack.token: must be set"},
+		{"ui: tty", `ui: unknown UI "tty"`},
+		{"ui: discord", "discord.token: must be set"},
+		{"ui: irc", "irc.server: must be set"},
+		{"ui: irc\nirc: {server: irc.test:6697, sasl_user: gobot}", "irc.sasl_user: must be set along with irc.sasl_password"},
+	}
+	dir := t.TempDir()
+	for i, tt := range tests {
+		path := filepath.Join(dir, "gobot.yaml")
+		if err := ioutil.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadConfig(path, env(nil)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("config %v: got error %v, want one containing %q", i, err, tt.want)
+		}
+	}
+}
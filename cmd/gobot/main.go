@@ -2,14 +2,20 @@ package main
 
 import (
 	"context"
-	"math/rand"
+	"expvar"
+	"flag"
+	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/mattikus/gobot/internal/gobot"
+	"github.com/mattikus/gobot/internal/gobot/cli"
+	"github.com/mattikus/gobot/internal/gobot/discord"
+	"github.com/mattikus/gobot/internal/gobot/irc"
 	"github.com/mattikus/gobot/internal/gobot/slack"
+	"github.com/mattikus/gobot/internal/gobot/store"
 	"github.com/mattikus/gobot/internal/modules"
 
 	"github.com/sirupsen/logrus"
@@ -29,37 +35,117 @@ var log *logrus.Logger = &logrus.Logger{
 }
 
 func main() {
-	rand.Seed(time.Now().UnixNano())
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run runs the bot until it's interrupted, returning why it couldn't if it stops early. Errors are
+// returned rather than logged fatally so that deferred cleanup, such as closing the store, still
+// happens.
+func run() error {
+	configPath := flag.String("config", os.Getenv("GOBOT_CONFIG"), "path to the YAML config file")
+	uiName := flag.String("ui", "", "slack, discord, irc, or cli to talk to the bot in the terminal (overrides BOT_UI)")
+	flag.Parse()
+
+	// The flag takes precedence over both the config file and the environment.
+	getenv := func(name string) string {
+		if name == "BOT_UI" && *uiName != "" {
+			return *uiName
+		}
+		return os.Getenv(name)
+	}
+	cfg, err := loadConfig(*configPath, getenv)
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	cfg.configureLog(log)
+
 	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	go cancelOnInterrupt(cancel, log)
+	if cfg.Metrics.Listen != "" {
+		go serveMetrics(cfg.Metrics.Listen, log)
+	}
 
-	name := os.Getenv("BOT_NAME")
-	if name == "" {
-		name = "gobot"
+	var ui snowman.UI
+	var slackUI *slack.Slack
+	owners := cfg.Owners
+	switch {
+	case cfg.UI == "cli":
+		ui = cli.New(os.Stdin, os.Stdout, cfg.Name)
+		// Whoever is at the terminal can do anything.
+		owners = append(owners, cli.UserID(cli.DefaultUser))
+	case cfg.UI == "discord":
+		ui = discord.New(cfg.Discord.Token, log)
+	case cfg.UI == "irc":
+		nick := cfg.IRC.Nick
+		if nick == "" {
+			nick = cfg.Name
+		}
+		ui = irc.New(irc.Config{
+			Server:           cfg.IRC.Server,
+			TLS:              cfg.IRC.TLS,
+			Nick:             nick,
+			SASLUser:         cfg.IRC.SASLUser,
+			SASLPassword:     cfg.IRC.SASLPassword,
+			NickServPassword: cfg.IRC.NickServPassword,
+			Channels:         cfg.IRC.Channels,
+		}, log)
+	case cfg.Slack.Mode == "events":
+		slackUI = slack.New(cfg.Slack.Token, cfg.Slack.SigningSecret, cfg.Slack.Listen, log)
+		ui = slackUI
+	case cfg.Slack.Mode == "socket":
+		slackUI = slack.NewSocketMode(cfg.Slack.Token, cfg.Slack.AppToken, log)
+		ui = slackUI
 	}
 
-	token := os.Getenv("API_TOKEN")
-	secret := os.Getenv("SIGNING_SECRET")
-	port := os.Getenv("PORT")
-	slackUI := slack.New(token, secret, port, log)
+	var st store.Store = store.NewMemory()
+	if cfg.StorePath != "" {
+		db, err := store.OpenBolt(cfg.StorePath)
+		if err != nil {
+			return fmt.Errorf("unable to open store: %w", err)
+		}
+		defer db.Close()
+		st = db
+	} else {
+		log.Warnf("store_path is not set, module state will be lost on restart")
+	}
+	if len(owners) == 0 {
+		log.Warnf("owners is not set, nobody will be able to give out roles or use privileged commands")
+	}
 
 	c := gobot.NewClassifier(slackUI)
 	proc := gobot.NewProcessor()
+	proc.SetUI(ui)
+	if cfg.RandSeed != nil {
+		proc.Seed(*cfg.RandSeed)
+	}
 
-	if err := modules.Register(c, proc); err != nil {
-		log.Fatalf("Error registering modules: %v", err)
+	if err := modules.Register(c, proc, modules.Deps{Store: st, Owners: owners, Settings: cfg.Modules}); err != nil {
+		return fmt.Errorf("unable to register modules: %w", err)
 	}
 
 	if err := snowman.Run(ctx,
-		snowman.WithName(name),
+		snowman.WithName(cfg.Name),
 		snowman.WithLogger(log),
-		snowman.WithUI(slackUI),
+		snowman.WithUI(ui),
 		snowman.WithClassifier(c),
 		snowman.WithProcessor(proc),
 	); err != nil {
-		log.Fatalf("bot exited with error: %v", err)
+		return fmt.Errorf("bot exited with error: %w", err)
 	}
+	return nil
+}
 
+// serveMetrics serves the metrics published with expvar on addr, independently of any UI.
+func serveMetrics(addr string, logger snowman.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	logger.Infof("serving metrics on %v", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		logger.Errorf("unable to serve metrics: %v", err)
+	}
 }
 
 func cancelOnInterrupt(cancel context.CancelFunc, logger snowman.Logger) {
@@ -3,9 +3,12 @@ module github.com/mattikus/gobot
 go 1.16
 
 require (
+	github.com/gorilla/websocket v1.4.2
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/sirupsen/logrus v1.6.0
 	github.com/slack-go/slack v0.7.4
 	github.com/spy16/snowman v0.1.0
 	github.com/stretchr/testify v1.4.0 // indirect
+	go.etcd.io/bbolt v1.3.6
+	gopkg.in/yaml.v2 v2.4.0
 )
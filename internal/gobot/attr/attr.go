@@ -0,0 +1,59 @@
+// Package attr names the message attributes which say where a message was said and where a reply
+// goes, independently of any UI. Each UI sets them on the messages it receives and reads them from
+// the messages it's asked to say, so that modules work the same on every UI. UIs may add attributes
+// of their own, prefixed with their name, but modules don't rely on them.
+package attr
+
+// Attributes of the messages a UI receives.
+const (
+	// ToBot is true when a message was directed at the bot, by mentioning it or in a DM, as a bool.
+	ToBot = "to_bot"
+	// DM is true when a message was said in a DM with the bot, as a bool.
+	DM = "dm"
+	// Unverified is true when the UI couldn't verify who sent a message, as a bool, such as on IRC
+	// when the sender isn't logged in to services. Their user ID is still unique to them for now,
+	// but anyone could use it later, so they're never trusted with a role.
+	Unverified = "unverified"
+	// ID identifies a message within its channel, as a string, so that it can be replied to or
+	// updated. For reactions, it's the message reacted to.
+	ID = "id"
+	// Author is the user ID of whoever wrote the message reacted to, as a string.
+	Author = "author"
+	// Reaction is the name of the emoji, without colons, when the message is a reaction being added
+	// to or removed from another message, as a string.
+	Reaction = "reaction"
+	// ReactionRemoved is true when the reaction was removed rather than added, as a bool.
+	ReactionRemoved = "reaction_removed"
+	// Action is the action ID of the element, such as a button, the user interacted with, as a
+	// string. The message it belongs to is the one with the message's ID.
+	Action = "action_id"
+	// ActionValue is the value of the element the user interacted with, as a string.
+	ActionValue = "action_value"
+)
+
+// Attributes of both the messages a UI receives and those it says.
+const (
+	// Channel is the ID of the channel a message was said in, or is posted to, as a string. DMs
+	// are channels too.
+	Channel = "channel"
+	// Thread is the ID of the message starting the thread a message was said in, or is posted to,
+	// as a string. It's empty outside of threads.
+	Thread = "thread"
+	// Response is whatever a UI needs to respond to a command rather than posting a new message,
+	// such as Slack's response URL. It's copied from commands to their replies as it is.
+	Response = "response"
+)
+
+// Attributes of the messages a UI says.
+const (
+	// Broadcast is true when a message posted in a thread is also shown in its channel, as a bool.
+	Broadcast = "broadcast"
+	// Update is the ID of a message in the channel to replace with this one, as a string.
+	Update = "update"
+	// DMUser is the ID of a user to send a message to in their DM with the bot instead of posting it
+	// in a channel, as a string.
+	DMUser = "dm_user"
+	// Ephemeral is the ID of the only user who should see a message posted in a channel, as a
+	// string. UIs which can't hide messages from everyone else make sure it's clear who it's for.
+	Ephemeral = "ephemeral_user"
+)
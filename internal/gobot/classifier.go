@@ -3,26 +3,52 @@ package gobot
 import (
 	"context"
 	"errors"
+	"fmt"
 	"regexp"
+	"sync"
 
 	"github.com/spy16/snowman"
 
+	"github.com/mattikus/gobot/internal/gobot/attr"
 	"github.com/mattikus/gobot/internal/gobot/slack"
 )
 
 type kind int
 
-type patterns []struct {
-	re *regexp.Regexp
-	id string
+type patterns []intentPattern
+
+// without returns the patterns which aren't for intentID, and whether any were.
+func (ps patterns) without(intentID string) (patterns, bool) {
+	var out patterns
+	for _, p := range ps {
+		if p.id != intentID {
+			out = append(out, p)
+		}
+	}
+	return out, len(out) != len(ps)
 }
 
 // Classifier implements a simple intent classifier using regular expression
-// patterns. Zero value is safe for use.
+// patterns. Zero value is safe for use, and registrations can be changed while
+// messages are being classified.
 type Classifier struct {
 	slack *slack.Slack
-	hear  patterns
-	reply patterns
+
+	// mu guards the registrations below.
+	mu      sync.RWMutex
+	hear    patterns
+	reply   patterns
+	actions map[string]string
+
+	reactions map[reaction]string
+	// registered counts the patterns registered so far, recording the order they were registered in.
+	registered int
+}
+
+// reaction identifies an emoji reaction being added to, or removed from, a message.
+type reaction struct {
+	name    string
+	removed bool
 }
 
 // NewClassifier returns a pointer to a Classifier instance.
@@ -33,65 +59,178 @@ func NewClassifier(slack *slack.Slack) *Classifier {
 var unknown = snowman.Intent{ID: snowman.SysIntentUnknown}
 
 // Classify detects whether a message is directed at the bot and returns an appropriate intent, if
-// possible. Otherwise, it returns SysIntentUnknown.
+// possible. Otherwise, it returns SysIntentUnknown. A message ending in `--seed <n>` is classified
+// without it, and the seed is added to the intent context so the outcome can be reproduced.
 func (c *Classifier) Classify(_ context.Context, msg snowman.Msg) (snowman.Intent, error) {
-	toBot, ok := msg.Attribs["to_bot"].(bool)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if actionID, ok := msg.Attribs[attr.Action].(string); ok {
+		return c.classifyAction(actionID, msg), nil
+	}
+	if name, ok := msg.Attribs[attr.Reaction].(string); ok {
+		return c.classifyReaction(name, msg), nil
+	}
+	toBot, ok := msg.Attribs[attr.ToBot].(bool)
 	if !ok {
 		return snowman.Intent{}, errors.New("can't get to_bool")
 	}
-	if toBot {
-		intent, err := c.classify(c.reply, msg.Body)
-		if err != nil {
-			return unknown, err
-		}
-		if intent.ID != snowman.SysIntentUnknown {
-			return intent, nil
+	body, seed, seeded := splitSeed(msg.Body)
+	intent, also := pick(c.match(body, toBot))
+	if seeded && intent.ID != snowman.SysIntentUnknown {
+		intent.Ctx[SeedKey] = seed
+		for _, in := range also {
+			in.Ctx[SeedKey] = seed
 		}
 	}
-	return c.classify(c.hear, msg.Body)
+	if len(also) > 0 {
+		intent.Ctx[AlsoKey] = also
+	}
+	return intent, nil
 }
 
-// classify iterates through each registered pattern and tries to match the msg body
-// with it. If a match is identified, all the named expressions are inserted into the
-// intent context and returned. If not match is found, returns SysIntentUnknown.
-func (c *Classifier) classify(ps patterns, msg string) (snowman.Intent, error) {
-	for _, p := range ps {
-		matches := p.re.FindStringSubmatch(msg)
-		if len(matches) > 0 {
-			in := snowman.Intent{ID: p.id, Ctx: map[string]interface{}{}}
-			names := p.re.SubexpNames()
-			for i, match := range matches {
-				in.Ctx[names[i]] = match
-			}
-			return in, nil
-		}
+// classifyAction looks up the intent registered for an interactive element. The value of the
+// element is inserted into the intent context.
+func (c *Classifier) classifyAction(actionID string, msg snowman.Msg) snowman.Intent {
+	id, ok := c.actions[actionID]
+	if !ok {
+		return unknown
+	}
+	value, _ := msg.Attribs[attr.ActionValue].(string)
+	return snowman.Intent{ID: id, Ctx: map[string]interface{}{"action_id": actionID, "value": value}}
+}
+
+// classifyReaction looks up the intent registered for a reaction. The reaction, and the author and
+// timestamp of the message reacted to, are inserted into the intent context.
+func (c *Classifier) classifyReaction(name string, msg snowman.Msg) snowman.Intent {
+	removed, _ := msg.Attribs[attr.ReactionRemoved].(bool)
+	id, ok := c.reactions[reaction{name: name, removed: removed}]
+	if !ok {
+		return unknown
 	}
-	return snowman.Intent{ID: snowman.SysIntentUnknown}, nil
+	author, _ := msg.Attribs[attr.Author].(string)
+	ts, _ := msg.Attribs[attr.ID].(string)
+	return snowman.Intent{ID: id, Ctx: map[string]interface{}{"reaction": name, "user": author, "ts": ts}}
+}
+
+// Reaction registers the intent ID for an emoji reaction, named without colons, being added to a
+// message.
+func (c *Classifier) Reaction(name string, intentID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.addReaction(reaction{name: name}, intentID)
+}
+
+// ReactionRemoved registers the intent ID for an emoji reaction, named without colons, being
+// removed from a message.
+func (c *Classifier) ReactionRemoved(name string, intentID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.addReaction(reaction{name: name, removed: true}, intentID)
+}
+
+func (c *Classifier) addReaction(r reaction, intentID string) error {
+	if _, found := c.reactions[r]; found {
+		return fmt.Errorf("reaction %q is already registered", r.name)
+	}
+	if c.reactions == nil {
+		c.reactions = map[reaction]string{}
+	}
+	c.reactions[r] = intentID
+	return nil
+}
+
+// Action registers the intent ID for interactions with elements, such as buttons, which have the
+// given action ID.
+func (c *Classifier) Action(actionID string, intentID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, found := c.actions[actionID]; found {
+		return fmt.Errorf("action %q is already registered", actionID)
+	}
+	if c.actions == nil {
+		c.actions = map[string]string{}
+	}
+	c.actions[actionID] = intentID
+	return nil
 }
 
 // Hear registers the pattern and intent ID for message which are just overheard, not directed to
 // the bot.
-func (c *Classifier) Hear(pattern string, intentID string) error {
-	re, err := regexp.Compile(pattern)
+func (c *Classifier) Hear(pattern string, intentID string, opts ...PatternOption) error {
+	p, err := newIntentPattern(pattern, intentID, opts)
 	if err != nil {
 		return err
 	}
-	c.hear = append(c.hear, struct {
-		re *regexp.Regexp
-		id string
-	}{re: re, id: intentID})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p.order = c.registered
+	c.registered++
+	c.hear = append(c.hear, p)
 	return nil
 }
 
 // Reply registers the pattern and intent ID for messages which are directed to the bot itself.
-func (c *Classifier) Reply(pattern string, intentID string) error {
+func (c *Classifier) Reply(pattern string, intentID string, opts ...PatternOption) error {
+	p, err := newIntentPattern(pattern, intentID, opts)
+	if err != nil {
+		return err
+	}
+	// Only one reply is ever made, so replies can't fire alongside each other.
+	p.multi = false
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p.order = c.registered
+	c.registered++
+	c.reply = append(c.reply, p)
+	return nil
+}
+
+// Replace swaps the pattern of the hear or reply registration for an intent ID, keeping its priority
+// and place in the order patterns were registered in. Messages are classified with either the old
+// pattern or the new one, never neither.
+func (c *Classifier) Replace(intentID string, pattern string) error {
 	re, err := regexp.Compile(pattern)
 	if err != nil {
 		return err
 	}
-	c.reply = append(c.reply, struct {
-		re *regexp.Regexp
-		id string
-	}{re: re, id: intentID})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, ps := range []patterns{c.hear, c.reply} {
+		for i := range ps {
+			if ps[i].id == intentID {
+				ps[i].re = re
+				ps[i].anchors = anchors(re)
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("intent %q has no pattern registered", intentID)
+}
+
+// Unregister removes every pattern, action and reaction registered for an intent ID, so that it's
+// no longer classified.
+func (c *Classifier) Unregister(intentID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var found, ok bool
+	c.hear, ok = c.hear.without(intentID)
+	found = found || ok
+	c.reply, ok = c.reply.without(intentID)
+	found = found || ok
+	for actionID, id := range c.actions {
+		if id == intentID {
+			delete(c.actions, actionID)
+			found = true
+		}
+	}
+	for r, id := range c.reactions {
+		if id == intentID {
+			delete(c.reactions, r)
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("intent %q is not registered", intentID)
+	}
 	return nil
 }
@@ -0,0 +1,277 @@
+package gobot
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot/attr"
+)
+
+func msg(body string, toBot bool) snowman.Msg {
+	return snowman.Msg{Body: body, Attribs: map[string]interface{}{attr.ToBot: toBot}}
+}
+
+func TestClassifier(t *testing.T) {
+	c := NewClassifier(nil)
+	if err := c.Reply(`^card(?: me)? ?(?P<count>\d*)$`, "cards.white"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Hear(`card`, "cards.heard"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Reply(`(`, "broken"); err == nil {
+		t.Errorf("expected an invalid pattern to be rejected")
+	}
+
+	tests := []struct {
+		body  string
+		toBot bool
+		id    string
+		ctx   map[string]interface{}
+	}{
+		{"card me 3", true, "cards.white", map[string]interface{}{"count": "3"}},
+		{"card me 3", false, "cards.heard", nil},
+		{"wildcard", true, "cards.heard", nil},
+		{"hello", true, snowman.SysIntentUnknown, nil},
+		{"card me 3 --seed 42", true, "cards.white", map[string]interface{}{"count": "3", SeedKey: int64(42)}},
+		{"card --seed=-1", false, "cards.heard", map[string]interface{}{SeedKey: int64(-1)}},
+	}
+	for _, tt := range tests {
+		intent, err := c.Classify(context.Background(), msg(tt.body, tt.toBot))
+		if err != nil {
+			t.Fatalf("Classify(%q): %v", tt.body, err)
+		}
+		if intent.ID != tt.id {
+			t.Errorf("Classify(%q) = %q, want %q", tt.body, intent.ID, tt.id)
+		}
+		for k, v := range tt.ctx {
+			if intent.Ctx[k] != v {
+				t.Errorf("Classify(%q).Ctx[%q] = %v, want %v", tt.body, k, intent.Ctx[k], v)
+			}
+		}
+	}
+
+	if _, err := c.Classify(context.Background(), snowman.Msg{Body: "card"}); err == nil {
+		t.Errorf("expected an error classifying a message without to_bot")
+	}
+}
+
+func TestClassifierAction(t *testing.T) {
+	c := NewClassifier(nil)
+	if err := c.Reply(`^cards.deal_again$`, "cards.typed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Action("cards.deal_again", "cards.white.again"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Action("cards.deal_again", "cards.other"); err == nil {
+		t.Errorf("expected a duplicate action to be rejected")
+	}
+
+	click := func(actionID, value string) snowman.Msg {
+		return snowman.Msg{Body: actionID, Attribs: map[string]interface{}{
+			attr.ToBot:       true,
+			attr.Action:      actionID,
+			attr.ActionValue: value,
+		}}
+	}
+	intent, err := c.Classify(context.Background(), click("cards.deal_again", "3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if intent.ID != "cards.white.again" || intent.Ctx["value"] != "3" || intent.Ctx["action_id"] != "cards.deal_again" {
+		t.Errorf("Classify(click) = %+v, want cards.white.again with value 3", intent)
+	}
+	if intent, _ := c.Classify(context.Background(), click("unknown", "")); intent.ID != snowman.SysIntentUnknown {
+		t.Errorf("Classify(unknown click) = %q, want %q", intent.ID, snowman.SysIntentUnknown)
+	}
+}
+
+func TestClassifierReaction(t *testing.T) {
+	c := NewClassifier(nil)
+	if err := c.Reaction("card", "cards.reaction"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ReactionRemoved("card", "cards.unreaction"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Reaction("card", "cards.other"); err == nil {
+		t.Errorf("expected a duplicate reaction to be rejected")
+	}
+
+	react := func(name string, removed bool) snowman.Msg {
+		return snowman.Msg{Body: ":" + name + ":", Attribs: map[string]interface{}{
+			attr.ToBot:           false,
+			attr.Author:          "UBOB",
+			attr.ID:              "1.000001",
+			attr.Reaction:        name,
+			attr.ReactionRemoved: removed,
+		}}
+	}
+	tests := []struct {
+		name    string
+		removed bool
+		id      string
+	}{
+		{"card", false, "cards.reaction"},
+		{"card", true, "cards.unreaction"},
+		{"fire", false, snowman.SysIntentUnknown},
+	}
+	for _, tt := range tests {
+		intent, err := c.Classify(context.Background(), react(tt.name, tt.removed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if intent.ID != tt.id {
+			t.Errorf("Classify(%v, removed=%v) = %q, want %q", tt.name, tt.removed, intent.ID, tt.id)
+		}
+	}
+	intent, _ := c.Classify(context.Background(), react("card", false))
+	if intent.Ctx["user"] != "UBOB" || intent.Ctx["ts"] != "1.000001" {
+		t.Errorf("expected the message reacted to in the intent context, got %v", intent.Ctx)
+	}
+}
+
+func TestClassifierReplace(t *testing.T) {
+	c := NewClassifier(nil)
+	if err := c.Hear(`^!(?P<trigger>maul)$`, "antisocial"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Replace("antisocial", `^!(?P<trigger>maul|bonk)$`); err != nil {
+		t.Fatal(err)
+	}
+	intent, err := c.Classify(context.Background(), msg("!bonk", false))
+	if err != nil || intent.ID != "antisocial" || intent.Ctx["trigger"] != "bonk" {
+		t.Errorf("Classify(!bonk) = %+v, %v, want the replaced pattern to match", intent, err)
+	}
+	if err := c.Replace("missing", `x`); err == nil {
+		t.Errorf("expected replacing an unregistered intent to fail")
+	}
+	if err := c.Replace("antisocial", `(`); err == nil {
+		t.Errorf("expected an invalid pattern to be rejected")
+	}
+}
+
+func TestClassifierUnregister(t *testing.T) {
+	c := NewClassifier(nil)
+	for _, err := range []error{
+		c.Hear(`^!maul$`, "antisocial"),
+		c.Reply(`^karma$`, "karma"),
+		c.Reaction("fire", "antisocial"),
+		c.Action("maul_again", "antisocial"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Unregister("antisocial"); err != nil {
+		t.Fatal(err)
+	}
+	if intent, _ := c.Classify(context.Background(), msg("!maul", false)); intent.ID != snowman.SysIntentUnknown {
+		t.Errorf("expected an unregistered pattern not to match, got %q", intent.ID)
+	}
+	if intent, _ := c.Classify(context.Background(), msg("karma", true)); intent.ID != "karma" {
+		t.Errorf("expected other intents to stay registered, got %q", intent.ID)
+	}
+	if err := c.Reaction("fire", "other"); err != nil {
+		t.Errorf("expected the reaction to be free after unregistering, got %v", err)
+	}
+	if err := c.Unregister("antisocial"); err == nil {
+		t.Errorf("expected unregistering an unknown intent to fail")
+	}
+}
+
+func TestClassifierConcurrent(t *testing.T) {
+	c := NewClassifier(nil)
+	if err := c.Hear(`^!maul$`, "antisocial"); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			c.Replace("antisocial", `^!(?:maul|bonk)$`)
+			c.Reply(fmt.Sprintf(`^cmd%d$`, i), fmt.Sprintf("cmd%d", i))
+			c.Unregister(fmt.Sprintf("cmd%d", i))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if intent, err := c.Classify(context.Background(), msg("!maul", true)); err != nil || intent.ID != "antisocial" {
+			t.Fatalf("Classify(!maul) = %q, %v while registrations change", intent.ID, err)
+		}
+	}
+	<-done
+}
+
+func TestClassifierRanking(t *testing.T) {
+	c := NewClassifier(nil)
+	for _, err := range []error{
+		c.Reply(`card(?: me)? (?P<count>\d*)?`, "cards.white"),
+		c.Reply(`q(?:uestion)? card(?: me)?`, "cards.black"),
+		c.Reply(`^karma(?: (?P<board>top|bottom))?$`, "karma.board"),
+		c.Reply(`^karma (?P<thing>.+)$`, "karma.info"),
+		c.Reply(`karma`, "karma.loose"),
+		c.Reply(`^ping`, "ping"),
+		c.Reply(`ping`, "ping.urgent", Priority(1)),
+		c.Hear(`\+\+`, "karma"),
+		c.Hear(`!\w+`, "antisocial"),
+		c.Hear(`bob`, "bob", MultiMatch()),
+		c.Reply(`^hi bob$`, "hi", MultiMatch()),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		body  string
+		toBot bool
+		id    string
+		also  []string
+	}{
+		// More of the message is spelled out by the pattern.
+		{"q card me 2", true, "cards.black", nil},
+		{"card me 2", true, "cards.white", nil},
+		// Anchored patterns beat unanchored ones, then specificity decides.
+		{"karma top", true, "karma.board", nil},
+		{"karma foo", true, "karma.info", nil},
+		// Priority beats anchoring.
+		{"ping", true, "ping.urgent", nil},
+		{"!maul++", false, "antisocial", nil},
+		// Ties go to the pattern registered first.
+		{"!a++", false, "karma", nil},
+		// Multi-match patterns fire alongside the winner, or win if nothing else matches.
+		{"bob++", false, "karma", []string{"bob"}},
+		{"bob", false, "bob", nil},
+		// Reply patterns can't fire alongside others.
+		{"hi bob", true, "hi", []string{"bob"}},
+	}
+	for _, tt := range tests {
+		intent, err := c.Classify(context.Background(), msg(tt.body, tt.toBot))
+		if err != nil {
+			t.Fatalf("Classify(%q): %v", tt.body, err)
+		}
+		if intent.ID != tt.id {
+			t.Errorf("Classify(%q) = %q, want %q", tt.body, intent.ID, tt.id)
+		}
+		also, _ := intent.Ctx[AlsoKey].([]snowman.Intent)
+		var ids []string
+		for _, in := range also {
+			ids = append(ids, in.ID)
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(tt.also) {
+			t.Errorf("Classify(%q) also fired %v, want %v", tt.body, ids, tt.also)
+		}
+	}
+
+	ms := c.Explain("karma top --seed 4", true)
+	if len(ms) != 3 || ms[0].IntentID != "karma.board" || !ms[0].Won || ms[1].IntentID != "karma.info" || ms[1].Won {
+		t.Fatalf("Explain(karma top) = %+v, want karma.board to beat karma.info and karma.loose", ms)
+	}
+	if ms[0].Anchors != 2 || ms[2].Anchors != 0 || ms[0].Specificity != 6 || ms[1].Specificity != 6 {
+		t.Errorf("Explain(karma top) = %+v, want anchors 2 and 0 and specificity 6", ms)
+	}
+}
@@ -0,0 +1,436 @@
+// Package cli provides a snowman.UI which talks to the bot over a terminal. It simulates the users,
+// channels, threads, DMs and mentions of a Slack workspace so that modules can be tried out without
+// one.
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot/attr"
+	"github.com/mattikus/gobot/internal/gobot/render"
+	"github.com/mattikus/gobot/internal/gobot/rich"
+)
+
+// DefaultUser is the name of the user who speaks until another is chosen with /as.
+const DefaultUser = "dev"
+
+// DefaultChannel is the name of the channel users speak in until another is chosen with /in.
+const DefaultChannel = "general"
+
+// UserID returns the ID of the simulated user with the given name.
+func UserID(name string) string {
+	return "U" + strings.ToUpper(name)
+}
+
+// channelID returns the ID of the simulated channel with the given name.
+func channelID(name string) string {
+	return "C" + strings.ToUpper(name)
+}
+
+var (
+	// userRefExp matches a user being mentioned in what's typed, as in "@alice".
+	userRefExp = regexp.MustCompile(`(^|\s)@(\w+)`)
+	// channelRefExp matches a channel being referred to in what's typed, as in "#random".
+	channelRefExp = regexp.MustCompile(`(^|\s)#(\w+)`)
+	// mentionExp matches a mention of a user or channel in what the bot says.
+	mentionExp = regexp.MustCompile(`<([@#])([A-Z0-9]+)(?:\|([^>]*))?>`)
+)
+
+// help describes the commands understood by the CLI.
+const help = `Type a message to send it to the current channel, starting with @%[1]v to address the bot.
+  /as <user>                   speak as another user
+  /in <channel>                speak in another channel
+  /dm                          speak to the bot directly
+  /thread [ts]                 speak in the thread of a message, or leave it
+  /react <ts> <emoji>          react to a message
+  /unreact <ts> <emoji>        remove a reaction from a message
+  /click <ts> <action> [value] click a button in a message from the bot
+  /slash <text>                run /%[1]v <text>
+  /quit                        stop the bot`
+
+// posted is a message which was sent, kept so that it can be reacted to and clicked.
+type posted struct {
+	user    string
+	channel string
+	thread  string
+	body    string
+	content []rich.Block
+}
+
+// CLI is a snowman.UI which reads what users say from one stream, a line at a time, and writes
+// everything said to another.
+type CLI struct {
+	in   io.Reader
+	name string
+
+	mu      sync.Mutex
+	out     io.Writer
+	user    string
+	channel string
+	thread  string
+	ts      int
+	posts   map[string]posted
+	// names maps the IDs of the users and channels seen so far to their names.
+	names map[string]string
+}
+
+// New returns a pointer to a CLI which reads from in and writes to out for a bot with the given
+// name.
+func New(in io.Reader, out io.Writer, name string) *CLI {
+	cli := &CLI{
+		in:    in,
+		out:   out,
+		name:  name,
+		posts: map[string]posted{},
+		names: map[string]string{},
+	}
+	cli.names[UserID(name)] = name
+	cli.setUser(DefaultUser)
+	cli.setChannel(DefaultChannel)
+	return cli
+}
+
+func (cli *CLI) setUser(name string) {
+	cli.user = UserID(name)
+	cli.names[cli.user] = name
+}
+
+func (cli *CLI) setChannel(name string) {
+	cli.channel = channelID(name)
+	cli.thread = ""
+	cli.names[cli.channel] = name
+}
+
+func dmChannel(userID string) string {
+	return "D" + userID
+}
+
+// Listen implements snowman.UI. Lines are read until the input ends, /quit is typed or ctx is
+// cancelled, after which the returned channel is closed.
+func (cli *CLI) Listen(ctx context.Context) (<-chan snowman.Msg, error) {
+	out := make(chan snowman.Msg)
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(cli.in)
+		for scanner.Scan() {
+			select {
+			case <-ctx.Done():
+				return
+			case lines <- scanner.Text():
+			}
+		}
+	}()
+	go func() {
+		defer close(out)
+		cli.printf(help+"\n", cli.name)
+		for {
+			var line string
+			var ok bool
+			select {
+			case <-ctx.Done():
+				return
+			case line, ok = <-lines:
+				if !ok {
+					return
+				}
+			}
+			msg, quit, err := cli.handle(strings.TrimSpace(line))
+			if err != nil {
+				cli.printf("error: %v\n", err)
+				continue
+			}
+			if quit {
+				return
+			}
+			if msg == nil {
+				continue
+			}
+			// The bot drops messages without a body without handling them, so once it takes the
+			// second one it has finished replying to the first. Waiting for it keeps what's typed
+			// next after the replies, in the transcript and in the order messages are sent.
+			for _, m := range []snowman.Msg{*msg, {}} {
+				select {
+				case <-ctx.Done():
+					return
+				case out <- m:
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// handle turns a line which was typed into a message for the bot, if there is one.
+func (cli *CLI) handle(line string) (*snowman.Msg, bool, error) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	if !strings.HasPrefix(line, "/") {
+		if line == "" {
+			return nil, false, nil
+		}
+		return cli.message(line, false), false, nil
+	}
+
+	args := strings.Fields(line)
+	switch cmd := args[0]; {
+	case cmd == "/quit":
+		return nil, true, nil
+	case cmd == "/help":
+		fmt.Fprintf(cli.out, help+"\n", cli.name)
+	case cmd == "/as" && len(args) == 2:
+		cli.setUser(strings.TrimPrefix(args[1], "@"))
+		if strings.HasPrefix(cli.channel, "D") {
+			cli.channel = dmChannel(cli.user)
+		}
+	case cmd == "/in" && len(args) == 2:
+		cli.setChannel(strings.TrimPrefix(args[1], "#"))
+	case cmd == "/dm" && len(args) == 1:
+		cli.channel = dmChannel(cli.user)
+		cli.thread = ""
+	case cmd == "/thread" && len(args) == 1:
+		cli.thread = ""
+	case cmd == "/thread" && len(args) == 2:
+		ts := timeStamp(args[1])
+		if _, ok := cli.posts[ts]; !ok {
+			return nil, false, fmt.Errorf("no message %v", args[1])
+		}
+		cli.thread = ts
+	case (cmd == "/react" || cmd == "/unreact") && len(args) == 3:
+		msg, err := cli.reaction(timeStamp(args[1]), strings.Trim(args[2], ":"), cmd == "/unreact")
+		return msg, false, err
+	case cmd == "/click" && (len(args) == 3 || len(args) == 4):
+		value := ""
+		if len(args) == 4 {
+			value = args[3]
+		}
+		msg, err := cli.click(timeStamp(args[1]), args[2], value)
+		return msg, false, err
+	case cmd == "/slash" && len(args) > 1:
+		msg := cli.message(strings.TrimSpace(strings.TrimPrefix(line, cmd)), true)
+		msg.Attribs[attr.Response] = "cli"
+		return msg, false, nil
+	default:
+		return nil, false, fmt.Errorf("unknown command %q, try /help", line)
+	}
+	return nil, false, nil
+}
+
+// timeStamp returns the timestamp of a message, which can be typed without the fraction.
+func timeStamp(s string) string {
+	if !strings.Contains(s, ".") {
+		return s + ".000000"
+	}
+	return s
+}
+
+// nextTimeStamp returns the timestamp of the next message sent. The caller must hold cli.mu.
+func (cli *CLI) nextTimeStamp() string {
+	cli.ts++
+	return strconv.Itoa(cli.ts) + ".000000"
+}
+
+// message builds a message the way the Slack UI would for the current user speaking where they
+// are. Messages starting with a mention of the bot, or sent in a DM, are addressed to it. The caller
+// must hold cli.mu.
+func (cli *CLI) message(text string, toBot bool) *snowman.Msg {
+	cli.echo(text)
+	if rest := strings.TrimPrefix(text, "@"+cli.name); rest != text && (rest == "" || !isWordChar(rest[0])) {
+		text = strings.TrimLeft(rest, ":, ")
+		toBot = true
+	}
+	text = userRefExp.ReplaceAllStringFunc(text, func(s string) string {
+		m := userRefExp.FindStringSubmatch(s)
+		cli.names[UserID(m[2])] = m[2]
+		return m[1] + "<@" + UserID(m[2]) + ">"
+	})
+	text = channelRefExp.ReplaceAllStringFunc(text, func(s string) string {
+		m := channelRefExp.FindStringSubmatch(s)
+		cli.names[channelID(m[2])] = m[2]
+		return m[1] + "<#" + channelID(m[2]) + "|" + m[2] + ">"
+	})
+
+	ts := cli.nextTimeStamp()
+	dm := strings.HasPrefix(cli.channel, "D")
+	cli.posts[ts] = posted{user: cli.user, channel: cli.channel, thread: cli.thread, body: text}
+	return &snowman.Msg{
+		From: cli.from(),
+		Body: text,
+		Attribs: map[string]interface{}{
+			attr.Channel: cli.channel,
+			attr.ID:      ts,
+			attr.Thread:  cli.thread,
+			attr.DM:      dm,
+			attr.ToBot:   toBot || dm,
+		},
+	}
+}
+
+// echo writes what the current user said, with the timestamp it's about to be sent with so that it
+// can be referred to. The caller must hold cli.mu.
+func (cli *CLI) echo(text string) {
+	fmt.Fprintf(cli.out, "[%d] %v <%v> %v\n", cli.ts+1, cli.where(cli.channel, cli.thread), cli.names[cli.user], text)
+}
+
+func (cli *CLI) from() snowman.User {
+	return snowman.User{ID: cli.user, Name: cli.names[cli.user]}
+}
+
+func isWordChar(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+// reaction builds a message for the current user reacting to a message. The caller must hold
+// cli.mu.
+func (cli *CLI) reaction(ts, name string, removed bool) (*snowman.Msg, error) {
+	p, ok := cli.posts[ts]
+	if !ok {
+		return nil, fmt.Errorf("no message %v", ts)
+	}
+	return &snowman.Msg{
+		From: cli.from(),
+		Body: ":" + name + ":",
+		Attribs: map[string]interface{}{
+			attr.Channel:         p.channel,
+			attr.ID:              ts,
+			attr.Author:          p.user,
+			attr.DM:              strings.HasPrefix(p.channel, "D"),
+			attr.Reaction:        name,
+			attr.ReactionRemoved: removed,
+			attr.ToBot:           false,
+		},
+	}, nil
+}
+
+// click builds a message for the current user clicking a button in a message from the bot. The
+// value of the button is used unless one is given. The caller must hold cli.mu.
+func (cli *CLI) click(ts, actionID, value string) (*snowman.Msg, error) {
+	p, ok := cli.posts[ts]
+	if !ok {
+		return nil, fmt.Errorf("no message %v", ts)
+	}
+	found := false
+	for _, b := range p.content {
+		if buttons, ok := b.(rich.Buttons); ok {
+			for _, btn := range buttons {
+				if btn.ActionID == actionID {
+					found = true
+					if value == "" {
+						value = btn.Value
+					}
+				}
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("message %v has no button %q", ts, actionID)
+	}
+	return &snowman.Msg{
+		From: cli.from(),
+		Body: actionID,
+		Attribs: map[string]interface{}{
+			attr.Channel:     p.channel,
+			attr.ID:          ts,
+			attr.Thread:      p.thread,
+			attr.DM:          strings.HasPrefix(p.channel, "D"),
+			attr.Action:      actionID,
+			attr.ActionValue: value,
+			attr.ToBot:       true,
+		},
+	}, nil
+}
+
+// Say implements snowman.UI by writing the message, along with where it was posted and the
+// timestamp it can be referred to by. Like a real UI, messages without a body or blocks aren't
+// posted.
+func (cli *CLI) Say(_ context.Context, _ snowman.User, msg snowman.Msg) error {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	channel, _ := msg.Attribs[attr.Channel].(string)
+	if to, ok := msg.Attribs[attr.DMUser].(string); ok && to != "" {
+		channel = dmChannel(to)
+	}
+	content := rich.Blocks(msg)
+	if msg.Body == "" && len(content) == 0 {
+		return nil
+	}
+	thread, _ := msg.Attribs[attr.Thread].(string)
+
+	var notes []string
+	ts, _ := msg.Attribs[attr.Update].(string)
+	if ts != "" {
+		notes = append(notes, "edited")
+	} else {
+		ts = cli.nextTimeStamp()
+	}
+	if user, ok := msg.Attribs[attr.Ephemeral].(string); ok && user != "" {
+		notes = append(notes, "only visible to @"+cli.nameOf(user))
+	}
+	if broadcast, _ := msg.Attribs[attr.Broadcast].(bool); broadcast {
+		notes = append(notes, "also sent to the channel")
+	}
+	cli.posts[ts] = posted{user: UserID(cli.name), channel: channel, thread: thread, body: msg.Body, content: content}
+
+	text := msg.Body
+	if len(content) > 0 {
+		text = render.Text(content)
+	}
+	text = cli.resolve(text)
+	if len(notes) > 0 {
+		text = "(" + strings.Join(notes, ", ") + ") " + text
+	}
+	if strings.Contains(text, "\n") {
+		text = "\n  " + strings.ReplaceAll(text, "\n", "\n  ")
+	}
+	_, err := fmt.Fprintf(cli.out, "[%v] %v <%v> %v\n", strings.TrimSuffix(ts, ".000000"), cli.where(channel, thread), cli.name, text)
+	return err
+}
+
+// printf writes to the output while holding cli.mu, so that it isn't interleaved with messages.
+func (cli *CLI) printf(format string, args ...interface{}) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	fmt.Fprintf(cli.out, format, args...)
+}
+
+// nameOf returns the name of a user or channel with the given ID, or the ID if it isn't known. The
+// caller must hold cli.mu.
+func (cli *CLI) nameOf(id string) string {
+	if name, ok := cli.names[id]; ok {
+		return name
+	}
+	return id
+}
+
+// where describes a channel, and the thread within it if any. The caller must hold cli.mu.
+func (cli *CLI) where(channel, thread string) string {
+	s := "#" + cli.nameOf(channel)
+	if strings.HasPrefix(channel, "D") {
+		s = "@" + cli.nameOf(strings.TrimPrefix(channel, "D")) + " (DM)"
+	}
+	if thread != "" {
+		s += " (thread " + strings.TrimSuffix(thread, ".000000") + ")"
+	}
+	return s
+}
+
+// resolve replaces the mentions of users and channels in what the bot says with their names. The
+// caller must hold cli.mu.
+func (cli *CLI) resolve(text string) string {
+	return mentionExp.ReplaceAllStringFunc(text, func(s string) string {
+		m := mentionExp.FindStringSubmatch(s)
+		if m[3] != "" {
+			return m[1] + m[3]
+		}
+		return m[1] + cli.nameOf(m[2])
+	})
+}
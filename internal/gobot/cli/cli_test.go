@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot"
+	"github.com/mattikus/gobot/internal/gobot/attr"
+	"github.com/mattikus/gobot/internal/gobot/rich"
+)
+
+// reply builds a reply to msg the way modules do.
+func reply(msg snowman.Msg, body string) snowman.Msg {
+	return snowman.Msg{Body: body, Attribs: map[string]interface{}{
+		attr.Channel: msg.Attribs[attr.Channel],
+		attr.Thread:  msg.Attribs[attr.Thread],
+	}}
+}
+
+func TestCLI(t *testing.T) {
+	c := gobot.NewClassifier(nil)
+	pp := gobot.NewProcessor()
+	register := func(id string, fun snowman.ProcessorFunc) {
+		if err := pp.Register(id, fun); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, err := range []error{
+		c.Reply(`^ping (?P<who>.+)$`, "ping"),
+		c.Hear(`^hi$`, "hi"),
+		c.Reaction("fire", "fire"),
+		c.Reply(`^button$`, "button"),
+		c.Action("again", "again"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	register("ping", func(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+		return reply(intent.Msg, "pong "+intent.Ctx["who"].(string)+" in <#CGENERAL>"), nil
+	})
+	register("hi", func(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+		msg := reply(intent.Msg, "hi <@"+intent.Msg.From.ID+">")
+		msg.Attribs[attr.Ephemeral] = intent.Msg.From.ID
+		return msg, nil
+	})
+	register("fire", func(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+		return reply(intent.Msg, "<@"+intent.Ctx["user"].(string)+"> is on fire"), nil
+	})
+	register("button", func(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+		msg := reply(intent.Msg, "")
+		msg.Attribs[rich.Key] = []rich.Block{
+			rich.Text{Markdown: "*press*"},
+			rich.Buttons{{ActionID: "again", Value: "42", Label: "Again"}},
+		}
+		return msg, nil
+	})
+	register("again", func(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+		msg := reply(intent.Msg, "pressed "+intent.Ctx["value"].(string))
+		msg.Attribs[attr.Update] = intent.Msg.Attribs[attr.ID]
+		return msg, nil
+	})
+
+	in := strings.Join([]string{
+		"@gobot ping @bob",
+		"/as alice",
+		"hi",
+		"/react 1 fire",
+		"/in random",
+		"/thread 3",
+		"ping nobody",
+		"/dm",
+		"ping me",
+		"button",
+		"/click 10 again",
+		"/nope",
+		"/quit",
+		"never sent",
+	}, "\n")
+	out := &bytes.Buffer{}
+	ui := New(strings.NewReader(in), out, "gobot")
+	pp.SetUI(ui)
+	if err := snowman.Run(context.Background(),
+		snowman.WithName("gobot"),
+		snowman.WithLogger(snowman.NoOpLogger{}),
+		snowman.WithUI(ui),
+		snowman.WithClassifier(c),
+		snowman.WithProcessor(pp),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"[1] #general <dev> @gobot ping @bob\n",
+		"[2] #general <gobot> pong @bob in #general\n",
+		"[3] #general <alice> hi\n",
+		"[4] #general <gobot> (only visible to @alice) hi @alice\n",
+		"[5] #general <gobot> @dev is on fire\n",
+		"[6] #random (thread 3) <alice> ping nobody\n",
+		"[7] @alice (DM) <alice> ping me\n",
+		"[8] @alice (DM) <gobot> pong me in #general\n",
+		"[10] @alice (DM) <gobot> \n  *press*\n  [Again](again)\n",
+		"[10] @alice (DM) <gobot> (edited) pressed 42\n",
+		`error: unknown command "/nope", try /help`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%v", want, out)
+		}
+	}
+	if strings.Contains(out.String(), "never sent") {
+		t.Errorf("expected nothing to be read after /quit")
+	}
+}
@@ -0,0 +1,196 @@
+// Package discord provides a snowman.UI which connects to Discord over its gateway. Messages are
+// given the attributes described by package attr, so that modules work on it without changes.
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot/attr"
+	"github.com/mattikus/gobot/internal/gobot/render"
+	"github.com/mattikus/gobot/internal/gobot/rich"
+)
+
+// APIURL is the base URL of the Discord REST API.
+const APIURL = "https://discord.com/api/v10"
+
+// regexTmpl is a string which defines a regex used by stripSelf to match messages addressed
+// directly to us and then remove the bot's mention or name.
+const regexTmpl = `(?is)^(?:(?P<self><@!?%v>|%v\b)[:,]?)?\s*(?P<text>.*)$`
+
+// New returns a Discord UI which authenticates with the given bot token.
+func New(token string, logger logger) *Discord {
+	if logger == nil {
+		logger = snowman.NoOpLogger{}
+	}
+	return &Discord{
+		token:  token,
+		apiURL: APIURL,
+		dialer: websocket.DefaultDialer,
+		logger: logger,
+	}
+}
+
+// Discord implements snowman UI using the Discord gateway and REST API.
+type Discord struct {
+	logger
+
+	token     string
+	apiURL    string
+	dialer    *websocket.Dialer
+	self      User
+	selfRegex *regexp.Regexp
+}
+
+// User is a Discord user.
+type User struct {
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	GlobalName string `json:"global_name"`
+	Bot        bool   `json:"bot"`
+}
+
+// name returns the name the user is displayed with.
+func (u User) name() string {
+	if u.GlobalName != "" {
+		return u.GlobalName
+	}
+	return u.Username
+}
+
+// Listen looks up the bot's user and connects to the gateway, pushing message and button click
+// events to the returned channel until ctx is cancelled.
+func (d *Discord) Listen(ctx context.Context) (<-chan snowman.Msg, error) {
+	if err := d.call(ctx, http.MethodGet, "/users/@me", nil, &d.self); err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(fmt.Sprintf(regexTmpl, d.self.ID, regexp.QuoteMeta(d.self.Username)))
+	if err != nil {
+		return nil, err
+	}
+	d.selfRegex = re
+
+	out := make(chan snowman.Msg)
+	go d.listenGateway(ctx, out)
+	return out, nil
+}
+
+// Say posts a message to Discord. Discord only lets bots send messages just one user can see in
+// reply to an interaction, so other messages for a single user are posted in the channel mentioning
+// them instead. Messages too long for Discord are posted as several.
+func (d *Discord) Say(ctx context.Context, _ snowman.User, msg snowman.Msg) error {
+	channel, _ := msg.Attribs[attr.Channel].(string)
+	thread, _ := msg.Attribs[attr.Thread].(string)
+	if to, ok := msg.Attribs[attr.DMUser].(string); ok && to != "" {
+		var dm struct {
+			ID string `json:"id"`
+		}
+		if err := d.call(ctx, http.MethodPost, "/users/@me/channels", map[string]string{"recipient_id": to}, &dm); err != nil {
+			return fmt.Errorf("unable to open DM with %q: %w", to, err)
+		}
+		channel, thread = dm.ID, ""
+	}
+	if channel == "" {
+		d.Warnf("unable to get channel from context")
+		return nil
+	}
+
+	content := rich.Blocks(msg)
+	if len(content) > 0 {
+		d.Debugf("posting rich content to %v: %q", channel, render.Text(content))
+	}
+	m := newMessage(msg.Body, content)
+	if m.empty() {
+		return nil
+	}
+	if id, ok := msg.Attribs[attr.Update].(string); ok && id != "" {
+		// An update replaces a single message, so it keeps as much of the content as fits in one.
+		parts := m.split()
+		m.Content = parts[0].Content
+		return d.call(ctx, http.MethodPatch, "/channels/"+channel+"/messages/"+id, m, nil)
+	}
+	path := "/channels/" + channel + "/messages"
+	if to, ok := msg.Attribs[attr.Ephemeral].(string); ok && to != "" {
+		if ic, ok := msg.Attribs[attr.Response].(interaction); ok {
+			m.Flags = flagEphemeral
+			path = "/webhooks/" + ic.applicationID + "/" + ic.token
+		} else {
+			m.Content = strings.TrimSpace(rich.Mention(to) + " " + m.Content)
+		}
+	}
+	if thread != "" {
+		m.Reference = &reference{MessageID: thread}
+	}
+	for _, part := range m.split() {
+		if err := d.call(ctx, http.MethodPost, path, part, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Self returns the bot's user, once connected.
+func (d *Discord) Self() User { return d.self }
+
+// call makes a request to the REST API, sending body and decoding the response into out unless
+// either is nil.
+func (d *Discord) call(ctx context.Context, method, path string, body, out interface{}) error {
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, d.apiURL+path, &payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bot "+d.token)
+	req.Header.Set("User-Agent", "DiscordBot (https://github.com/mattikus/gobot, 1)")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%v %v: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%v %v: %v: %s", method, path, resp.Status, bytes.TrimSpace(msg))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("unable to decode %v %v response: %w", method, path, err)
+	}
+	return nil
+}
+
+// stripSelf removes any mention of the bot from the start of the text, reporting whether there was
+// one.
+func (d *Discord) stripSelf(text string) (string, bool) {
+	matches := d.selfRegex.FindStringSubmatch(text)
+	if matches == nil || len(matches) < 3 {
+		return text, false
+	}
+	return matches[2], matches[1] != ""
+}
+
+type logger interface {
+	Debugf(msg string, args ...interface{})
+	Infof(msg string, args ...interface{})
+	Warnf(msg string, args ...interface{})
+	Errorf(msg string, args ...interface{})
+}
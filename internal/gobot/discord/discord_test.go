@@ -0,0 +1,399 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot/attr"
+	"github.com/mattikus/gobot/internal/gobot/rich"
+)
+
+// fakeDiscord serves just enough of the Discord REST API for a bot to connect and post messages,
+// and a gateway which dispatches each of the given events in turn once the bot has identified.
+type fakeDiscord struct {
+	*httptest.Server
+	events     []payload
+	identify   chan identify
+	heartbeats chan *int64
+	requests   chan request
+}
+
+// request is a request to the REST API other than those made to connect.
+type request struct {
+	method string
+	path   string
+	body   message
+}
+
+// event builds the payload dispatching an event.
+func event(typ string, data interface{}) payload {
+	d, _ := json.Marshal(data)
+	return payload{Op: opDispatch, Type: typ, Data: d}
+}
+
+func newFakeDiscord(t *testing.T, events ...payload) *fakeDiscord {
+	f := &fakeDiscord{
+		events:     events,
+		identify:   make(chan identify, 1),
+		heartbeats: make(chan *int64, 16),
+		requests:   make(chan request, 16),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bot test-token" {
+			t.Errorf("%v %v called with Authorization %q", r.Method, r.URL.Path, got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/users/@me":
+			w.Write([]byte(`{"id":"100","username":"gobot","bot":true}`))
+		case r.URL.Path == "/gateway/bot":
+			json.NewEncoder(w).Encode(map[string]string{"url": "ws" + strings.TrimPrefix(f.URL, "http") + "/ws"})
+		case r.URL.Path == "/users/@me/channels":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			json.NewEncoder(w).Encode(map[string]string{"id": "D" + body["recipient_id"]})
+		default:
+			req := request{method: r.Method, path: r.URL.Path}
+			json.NewDecoder(r.Body).Decode(&req.body)
+			f.requests <- req
+			w.Write([]byte(`{}`))
+		}
+	})
+	mux.HandleFunc("/ws/", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("unable to upgrade websocket: %v", err)
+			return
+		}
+		defer conn.Close()
+		h, _ := json.Marshal(hello{HeartbeatInterval: 20})
+		if err := conn.WriteJSON(payload{Op: opHello, Data: h}); err != nil {
+			return
+		}
+		var p payload
+		if err := conn.ReadJSON(&p); err != nil || p.Op != opIdentify {
+			t.Errorf("expected identify, got %+v (err=%v)", p, err)
+			return
+		}
+		var id identify
+		json.Unmarshal(p.Data, &id)
+		f.identify <- id
+
+		events := append([]payload{event("READY", map[string]interface{}{"user": map[string]string{"id": "100"}})}, f.events...)
+		for i, ev := range events {
+			seq := int64(i + 1)
+			ev.Seq = &seq
+			if err := conn.WriteJSON(ev); err != nil {
+				return
+			}
+		}
+		for {
+			if err := conn.ReadJSON(&p); err != nil {
+				return
+			}
+			if p.Op != opHeartbeat {
+				continue
+			}
+			var seq *int64
+			json.Unmarshal(p.Data, &seq)
+			select {
+			case f.heartbeats <- seq:
+			default:
+			}
+			if err := conn.WriteJSON(payload{Op: opHeartbeatAck}); err != nil {
+				return
+			}
+		}
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func newTestDiscord(f *fakeDiscord) *Discord {
+	d := New("test-token", nil)
+	d.apiURL = f.URL
+	return d
+}
+
+func TestGateway(t *testing.T) {
+	alice := map[string]string{"id": "200", "username": "alice", "global_name": "Alice"}
+	msg := func(id, guild, content string, author interface{}) payload {
+		return event("MESSAGE_CREATE", map[string]interface{}{
+			"id": id, "channel_id": "300", "guild_id": guild, "author": author, "content": content,
+		})
+	}
+	f := newFakeDiscord(t,
+		msg("1", "400", "<@100> card me", map[string]string{"id": "100", "username": "gobot"}),
+		msg("2", "400", "<@!100> card me", alice),
+		msg("3", "400", "hello <@100> and <@!200>", alice),
+		msg("4", "", "baseball me", alice),
+		event("INTERACTION_CREATE", map[string]interface{}{
+			"id": "500", "application_id": "100", "token": "tok", "type": interactionComponent, "channel_id": "300", "guild_id": "400",
+			"member":  map[string]interface{}{"user": alice},
+			"message": map[string]interface{}{"id": "2", "content": "dealt"},
+			"data":    map[string]string{"custom_id": "cards.deal_again|2"},
+		}),
+	)
+	d := newTestDiscord(f)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgs, err := d.Listen(ctx)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+
+	select {
+	case id := <-f.identify:
+		want := intentGuildMessages | intentDirectMessages | intentMessageContent
+		if id.Token != "test-token" || id.Intents != want {
+			t.Errorf("identified with token %q and intents %b, want test-token and %b", id.Token, id.Intents, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for identify")
+	}
+
+	// The bot's own message is ignored, so the first message is alice's.
+	tests := []struct {
+		body  string
+		toBot bool
+		dm    bool
+		ts    string
+		value interface{}
+	}{
+		{"card me", true, false, "2", nil},
+		{"hello <@100> and <@200>", false, false, "3", nil},
+		{"baseball me", true, true, "4", nil},
+		{"cards.deal_again", true, false, "2", "2"},
+	}
+	for _, tt := range tests {
+		select {
+		case msg := <-msgs:
+			if msg.Body != tt.body || msg.From.ID != "200" || msg.From.Name != "Alice" || msg.Attribs["to_bot"] != tt.toBot {
+				t.Errorf("got message %q from %+v (to_bot=%v), want %q from alice (to_bot=%v)",
+					msg.Body, msg.From, msg.Attribs["to_bot"], tt.body, tt.toBot)
+			}
+			if msg.Attribs[attr.Channel] != "300" || msg.Attribs[attr.DM] != tt.dm || msg.Attribs[attr.ID] != tt.ts {
+				t.Errorf("%q: got message %v in %v (dm=%v), want %v in 300 (dm=%v)", tt.body,
+					msg.Attribs[attr.ID], msg.Attribs[attr.Channel], msg.Attribs[attr.DM], tt.ts, tt.dm)
+			}
+			if value := msg.Attribs[attr.ActionValue]; value != tt.value {
+				t.Errorf("%q: got action value %v, want %v", tt.body, value, tt.value)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %q", tt.body)
+		}
+	}
+
+	select {
+	case req := <-f.requests:
+		if req.method != http.MethodPost || req.path != "/interactions/500/tok/callback" {
+			t.Errorf("got %v %v, want the interaction to be acknowledged", req.method, req.path)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the interaction to be acknowledged")
+	}
+
+	// Heartbeats carry the sequence number of the last event, so wait for one sent after them all.
+	timeout := time.After(time.Second)
+	for seq := (*int64)(nil); seq == nil || *seq != 6; {
+		select {
+		case seq = <-f.heartbeats:
+		case <-timeout:
+			t.Fatal("timed out waiting for heartbeat")
+		}
+	}
+
+	cancel()
+	select {
+	case _, ok := <-msgs:
+		if ok {
+			t.Errorf("expected no more messages")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for listener to stop")
+	}
+}
+
+func TestSay(t *testing.T) {
+	f := newFakeDiscord(t)
+	d := newTestDiscord(f)
+
+	tests := []struct {
+		name    string
+		attribs map[string]interface{}
+		method  string
+		path    string
+		reply   string
+	}{
+		{"public", map[string]interface{}{attr.Channel: "300"}, http.MethodPost, "/channels/300/messages", ""},
+		{"thread", map[string]interface{}{attr.Channel: "300", attr.Thread: "2"}, http.MethodPost, "/channels/300/messages", "2"},
+		{"update", map[string]interface{}{attr.Channel: "300", attr.Update: "2"}, http.MethodPatch, "/channels/300/messages/2", ""},
+		{"dm", map[string]interface{}{attr.DMUser: "200"}, http.MethodPost, "/channels/D200/messages", ""},
+	}
+	for _, tt := range tests {
+		if err := d.Say(context.Background(), snowman.User{}, snowman.Msg{Body: "*hi*", Attribs: tt.attribs}); err != nil {
+			t.Fatalf("%v: Say: %v", tt.name, err)
+		}
+		req := <-f.requests
+		reply := ""
+		if req.body.Reference != nil {
+			reply = req.body.Reference.MessageID
+		}
+		if req.method != tt.method || req.path != tt.path || reply != tt.reply || req.body.Content != "**hi**" {
+			t.Errorf("%v: got %v %v replying to %q with %q, want %v %v replying to %q with %q", tt.name,
+				req.method, req.path, reply, req.body.Content, tt.method, tt.path, tt.reply, "**hi**")
+		}
+	}
+
+	// Messages for a single user are only hidden from everyone else in reply to an interaction, and
+	// otherwise mention who they're for.
+	ephemeral := []struct {
+		name    string
+		attribs map[string]interface{}
+		path    string
+		content string
+		flags   int
+	}{
+		{"ephemeral", map[string]interface{}{attr.Channel: "300", attr.Ephemeral: "200"}, "/channels/300/messages", "<@200> **hi**", 0},
+		{"ephemeral interaction", map[string]interface{}{
+			attr.Channel: "300", attr.Ephemeral: "200", attr.Response: interaction{applicationID: "100", token: "tok"},
+		}, "/webhooks/100/tok", "**hi**", flagEphemeral},
+	}
+	for _, tt := range ephemeral {
+		if err := d.Say(context.Background(), snowman.User{}, snowman.Msg{Body: "*hi*", Attribs: tt.attribs}); err != nil {
+			t.Fatalf("%v: Say: %v", tt.name, err)
+		}
+		req := <-f.requests
+		if req.path != tt.path || req.body.Content != tt.content || req.body.Flags != tt.flags {
+			t.Errorf("%v: got %v with %q (flags=%v), want %v with %q (flags=%v)", tt.name,
+				req.path, req.body.Content, req.body.Flags, tt.path, tt.content, tt.flags)
+		}
+	}
+
+	// Content too long for a single message is split at line breaks, with only the first replying in
+	// the thread.
+	line := strings.Repeat("é", 49)
+	long := strings.TrimSuffix(strings.Repeat(line+"\n", 60), "\n")
+	attribs := map[string]interface{}{attr.Channel: "300", attr.Thread: "2"}
+	if err := d.Say(context.Background(), snowman.User{}, snowman.Msg{Body: long, Attribs: attribs}); err != nil {
+		t.Fatalf("long: Say: %v", err)
+	}
+	var parts []string
+	for i := 0; i < 2; i++ {
+		req := <-f.requests
+		if n := utf8.RuneCountInString(req.body.Content); n > maxContent || !strings.HasSuffix(req.body.Content, line) {
+			t.Errorf("long: part %v has %v characters, ending %q", i, n, req.body.Content[len(req.body.Content)-10:])
+		}
+		if (req.body.Reference != nil) != (i == 0) {
+			t.Errorf("long: part %v replies to %+v", i, req.body.Reference)
+		}
+		parts = append(parts, req.body.Content)
+	}
+	if got := strings.Join(parts, "\n"); got != long {
+		t.Errorf("long: got %q, want %q", got, long)
+	}
+
+	err := d.Say(context.Background(), snowman.User{}, snowman.Msg{Attribs: map[string]interface{}{attr.Channel: "300"}})
+	if err != nil {
+		t.Fatalf("Say: %v", err)
+	}
+	select {
+	case req := <-f.requests:
+		t.Errorf("empty message posted as %v %v", req.method, req.path)
+	default:
+	}
+}
+
+func TestNewMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		content []rich.Block
+		want    message
+	}{
+		{
+			name: "body",
+			body: ">*Player:* <https://example.com|Odood> ~out~ `*code*`",
+			want: message{Content: "> **Player:** [Odood](https://example.com) ~~out~~ `*code*`"},
+		},
+		{
+			name: "image and text",
+			body: "fallback",
+			content: []rich.Block{
+				rich.Image{URL: "https://example.com/688.jpg", Alt: "Odood", Title: "Player"},
+				rich.Text{Markdown: "hi <@200|alice>"},
+			},
+			want: message{
+				Content: "hi <@200>",
+				Embeds:  []embed{{Title: "Player", Image: &embedImage{URL: "https://example.com/688.jpg"}}},
+			},
+		},
+		{
+			name: "fields describe an image",
+			content: []rich.Block{
+				rich.Image{URL: "https://example.com/faux.png", Alt: "Faux"},
+				rich.Fields{">>>*Name:*\nFaux Doadles", "Oregon"},
+			},
+			want: message{
+				Embeds: []embed{{
+					Image: &embedImage{URL: "https://example.com/faux.png"},
+					Fields: []embedField{
+						{Name: "**Name:**", Value: "Faux Doadles", Inline: true},
+						{Name: "\u200b", Value: "Oregon", Inline: true},
+					},
+				}},
+			},
+		},
+		{
+			name: "buttons",
+			body: "spin",
+			content: []rich.Block{
+				rich.Buttons{{ActionID: "emoji.spin_again", Label: "Spin again"}},
+			},
+			want: message{
+				Content: "spin",
+				Components: []component{{Type: componentRow, Components: []component{
+					{Type: componentButton, Style: buttonPrimary, Label: "Spin again", CustomID: "emoji.spin_again|"},
+				}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		got := newMessage(tt.body, tt.content)
+		want := tt.want
+		if want.Embeds == nil {
+			want.Embeds = []embed{}
+		}
+		if want.Components == nil {
+			want.Components = []component{}
+		}
+		want.Mentions = allowMention{Parse: []string{"users"}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: got %+v, want %+v", tt.name, got, want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	m := message{Content: strings.Repeat("a", maxContent+10), Embeds: []embed{{Title: "card"}}, Components: []component{}}
+	parts := m.split()
+	if len(parts) != 2 || len(parts[0].Content) != maxContent || parts[1].Content != strings.Repeat("a", 10) {
+		t.Fatalf("got %v parts, want the content cut at %v characters", len(parts), maxContent)
+	}
+	if len(parts[0].Embeds) != 0 || len(parts[1].Embeds) != 1 {
+		t.Errorf("got embeds %v and %v, want them with the last part", parts[0].Embeds, parts[1].Embeds)
+	}
+}
@@ -0,0 +1,379 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot/attr"
+)
+
+// reconnectDelay is how long to wait before opening a new gateway connection after the previous
+// one failed.
+const reconnectDelay = 5 * time.Second
+
+// Gateway opcodes.
+const (
+	opDispatch       = 0
+	opHeartbeat      = 1
+	opIdentify       = 2
+	opReconnect      = 7
+	opInvalidSession = 9
+	opHello          = 10
+	opHeartbeatAck   = 11
+)
+
+// Gateway intents, which select the events the gateway sends. Message content is a privileged
+// intent, which has to be enabled for the bot in the developer portal.
+const (
+	intentGuildMessages  = 1 << 9
+	intentDirectMessages = 1 << 12
+	intentMessageContent = 1 << 15
+)
+
+// interactionComponent is the type of interaction created by clicking a button.
+const interactionComponent = 3
+
+// interaction identifies an interaction, so that replies to it can be sent as follow up messages
+// which only the user who interacted sees.
+type interaction struct {
+	applicationID string
+	token         string
+}
+
+// callbackDeferredUpdate acknowledges an interaction, saying the message it came from may be
+// updated later.
+const callbackDeferredUpdate = 6
+
+// payload is the wrapper around everything sent over the gateway.
+type payload struct {
+	Op   int             `json:"op"`
+	Data json.RawMessage `json:"d"`
+	Seq  *int64          `json:"s,omitempty"`
+	Type string          `json:"t,omitempty"`
+}
+
+// hello is sent by the gateway as soon as a connection is opened.
+type hello struct {
+	HeartbeatInterval int `json:"heartbeat_interval"`
+}
+
+// identify authenticates a new gateway session.
+type identify struct {
+	Token      string            `json:"token"`
+	Intents    int               `json:"intents"`
+	Properties map[string]string `json:"properties"`
+}
+
+// messageCreate is the event dispatched when a message is posted.
+type messageCreate struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id"`
+	GuildID   string `json:"guild_id"`
+	Author    User   `json:"author"`
+	Content   string `json:"content"`
+}
+
+// interactionCreate is the event dispatched when a user interacts with the bot, such as by clicking
+// a button in one of its messages.
+type interactionCreate struct {
+	ID            string `json:"id"`
+	ApplicationID string `json:"application_id"`
+	Token         string `json:"token"`
+	Type          int    `json:"type"`
+	ChannelID     string `json:"channel_id"`
+	GuildID       string `json:"guild_id"`
+	// Member is set for interactions in a server, and User for those in a DM.
+	Member *struct {
+		User User `json:"user"`
+	} `json:"member"`
+	User    *User         `json:"user"`
+	Message messageCreate `json:"message"`
+	Data    struct {
+		CustomID string `json:"custom_id"`
+	} `json:"data"`
+}
+
+// gatewayConn is a gateway connection which can be written to by both the heartbeat and the
+// listener.
+type gatewayConn struct {
+	*websocket.Conn
+	mu sync.Mutex
+}
+
+func (c *gatewayConn) send(op int, data interface{}) error {
+	d, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.WriteJSON(payload{Op: op, Data: d})
+}
+
+// listenGateway keeps a gateway connection open until ctx is cancelled, reconnecting whenever
+// Discord asks us to or the connection drops. Sessions aren't resumed, so events dispatched while
+// reconnecting are missed.
+func (d *Discord) listenGateway(ctx context.Context, out chan<- snowman.Msg) {
+	defer close(out)
+
+	for {
+		err := d.runGateway(ctx, out)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			continue
+		}
+		d.Errorf("gateway connection failed: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+// runGateway opens a single gateway connection and processes events from it until it is closed. It
+// returns nil when Discord requested a reconnect.
+func (d *Discord) runGateway(ctx context.Context, out chan<- snowman.Msg) error {
+	var gw struct {
+		URL string `json:"url"`
+	}
+	if err := d.call(ctx, http.MethodGet, "/gateway/bot", nil, &gw); err != nil {
+		return err
+	}
+	ws, _, err := d.dialer.DialContext(ctx, gw.URL+"/?v=10&encoding=json", nil)
+	if err != nil {
+		return fmt.Errorf("unable to dial gateway: %w", err)
+	}
+	conn := &gatewayConn{Conn: ws}
+	defer conn.Close()
+
+	// Reads block forever, so unblock them by closing the connection once we're cancelled.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	var (
+		seqMu sync.Mutex
+		seq   *int64
+		acked = true
+	)
+	heartbeat := func() error {
+		seqMu.Lock()
+		s := seq
+		acked = false
+		seqMu.Unlock()
+		return conn.send(opHeartbeat, s)
+	}
+
+	d.Infof("listening for discord events over the gateway")
+	for {
+		var p payload
+		if err := conn.ReadJSON(&p); err != nil {
+			return fmt.Errorf("unable to read gateway payload: %w", err)
+		}
+		if p.Seq != nil {
+			seqMu.Lock()
+			seq = p.Seq
+			seqMu.Unlock()
+		}
+
+		switch p.Op {
+		case opHello:
+			var h hello
+			if err := json.Unmarshal(p.Data, &h); err != nil {
+				return fmt.Errorf("unable to parse hello: %w", err)
+			}
+			go func() {
+				ticker := time.NewTicker(time.Duration(h.HeartbeatInterval) * time.Millisecond)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-done:
+						return
+					case <-ticker.C:
+					}
+					seqMu.Lock()
+					zombie := !acked
+					seqMu.Unlock()
+					// A connection which stopped acknowledging heartbeats is dead, so drop it and
+					// start again.
+					if zombie {
+						d.Warnf("gateway stopped acknowledging heartbeats, reconnecting")
+						conn.Close()
+						return
+					}
+					if err := heartbeat(); err != nil {
+						return
+					}
+				}
+			}()
+			err := conn.send(opIdentify, identify{
+				Token:      d.token,
+				Intents:    intentGuildMessages | intentDirectMessages | intentMessageContent,
+				Properties: map[string]string{"os": "linux", "browser": "gobot", "device": "gobot"},
+			})
+			if err != nil {
+				return fmt.Errorf("unable to identify: %w", err)
+			}
+		case opHeartbeat:
+			if err := heartbeat(); err != nil {
+				return fmt.Errorf("unable to send heartbeat: %w", err)
+			}
+		case opHeartbeatAck:
+			seqMu.Lock()
+			acked = true
+			seqMu.Unlock()
+		case opReconnect:
+			d.Infof("gateway reconnect requested")
+			return nil
+		case opInvalidSession:
+			return fmt.Errorf("gateway session invalidated")
+		case opDispatch:
+			d.dispatch(ctx, p, out)
+		default:
+			d.Debugf("ignoring unknown gateway payload (op=%v)", p.Op)
+		}
+	}
+}
+
+// dispatch handles an event dispatched by the gateway.
+func (d *Discord) dispatch(ctx context.Context, p payload, out chan<- snowman.Msg) {
+	d.Debugf("event: %s [data=%s]", p.Type, p.Data)
+	switch p.Type {
+	case "READY":
+		d.Debugf("gateway session established")
+	case "MESSAGE_CREATE":
+		var ev messageCreate
+		if err := json.Unmarshal(p.Data, &ev); err != nil {
+			d.Errorf("unable to parse message: %v", err)
+			return
+		}
+		d.handleMessage(ctx, ev, out)
+	case "INTERACTION_CREATE":
+		var ic interactionCreate
+		if err := json.Unmarshal(p.Data, &ic); err != nil {
+			d.Errorf("unable to parse interaction: %v", err)
+			return
+		}
+		d.handleInteraction(ctx, ic, out)
+	default:
+		d.Debugf("ignoring unknown event (type=%v)", p.Type)
+	}
+}
+
+// attribs returns the attributes of a message, where message IDs stand in for Slack's timestamps
+// and messages outside of a server are DMs. Discord has no threads a bot can reply in, so replies
+// are posted alongside the message instead.
+func attribs(m messageCreate) map[string]interface{} {
+	return map[string]interface{}{
+		attr.Channel: m.ChannelID,
+		attr.ID:      m.ID,
+		attr.Thread:  "",
+		attr.DM:      m.GuildID == "",
+	}
+}
+
+// legacyMentionExp matches a mention of a user by their nickname in a server, which is otherwise
+// the same as mentioning them.
+var legacyMentionExp = regexp.MustCompile(`<@!(\d+)>`)
+
+func (d *Discord) handleMessage(ctx context.Context, m messageCreate, out chan<- snowman.Msg) {
+	if m.Author.ID == d.self.ID {
+		return
+	}
+
+	// Determine if the message was directly intended for us, stripping any mentions from the message
+	// text.
+	text, tagged := d.stripSelf(m.Content)
+	text = legacyMentionExp.ReplaceAllString(text, "<@$1>")
+
+	snowMsg := snowman.Msg{
+		From: snowman.User{
+			ID:   m.Author.ID,
+			Name: m.Author.name(),
+		},
+		Body:    text,
+		Attribs: attribs(m),
+	}
+	snowMsg.Attribs[attr.ToBot] = m.GuildID == "" || tagged
+
+	select {
+	case <-ctx.Done():
+	case out <- snowMsg:
+	}
+}
+
+// handleInteraction acknowledges a button being clicked and turns it into a message for the action
+// registered for the button.
+func (d *Discord) handleInteraction(ctx context.Context, ic interactionCreate, out chan<- snowman.Msg) {
+	if ic.Type != interactionComponent {
+		d.Debugf("ignoring unknown interaction (type=%v)", ic.Type)
+		return
+	}
+	// Discord shows the user an error unless the interaction is acknowledged within 3 seconds, which
+	// the action may well take longer than.
+	path := "/interactions/" + ic.ID + "/" + ic.Token + "/callback"
+	if err := d.call(ctx, http.MethodPost, path, map[string]int{"type": callbackDeferredUpdate}, nil); err != nil {
+		d.Errorf("unable to acknowledge interaction: %v", err)
+	}
+
+	var user User
+	switch {
+	case ic.Member != nil:
+		user = ic.Member.User
+	case ic.User != nil:
+		user = *ic.User
+	}
+	ic.Message.ChannelID = ic.ChannelID
+	ic.Message.GuildID = ic.GuildID
+	actionID, value := parseCustomID(ic.Data.CustomID)
+
+	snowMsg := snowman.Msg{
+		From: snowman.User{
+			ID:   user.ID,
+			Name: user.name(),
+		},
+		Body:    actionID,
+		Attribs: attribs(ic.Message),
+	}
+	snowMsg.Attribs[attr.Action] = actionID
+	snowMsg.Attribs[attr.ActionValue] = value
+	snowMsg.Attribs[attr.Response] = interaction{applicationID: ic.ApplicationID, token: ic.Token}
+	snowMsg.Attribs[attr.ToBot] = true
+
+	select {
+	case <-ctx.Done():
+	case out <- snowMsg:
+	}
+}
+
+// customID encodes the action ID and value of a button as the custom ID it's clicked with.
+func customID(actionID, value string) string {
+	return actionID + "|" + value
+}
+
+// parseCustomID decodes the action ID and value of a button from the custom ID it was clicked with.
+func parseCustomID(id string) (actionID, value string) {
+	parts := strings.SplitN(id, "|", 2)
+	if len(parts) < 2 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
@@ -0,0 +1,215 @@
+package discord
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mattikus/gobot/internal/gobot/rich"
+)
+
+// Limits on what a single message can hold. Content is counted in characters.
+const (
+	maxContent     = 2000
+	maxEmbeds      = 10
+	maxEmbedFields = 25
+	maxRowButtons  = 5
+	maxButtonRows  = 5
+)
+
+// flagEphemeral makes a follow up message to an interaction only visible to the user who
+// interacted.
+const flagEphemeral = 1 << 6
+
+// Types of components, and the style buttons are shown in.
+const (
+	componentRow    = 1
+	componentButton = 2
+	buttonPrimary   = 1
+)
+
+// message is a message posted with the REST API.
+type message struct {
+	Content    string       `json:"content"`
+	Embeds     []embed      `json:"embeds"`
+	Components []component  `json:"components"`
+	Reference  *reference   `json:"message_reference,omitempty"`
+	Mentions   allowMention `json:"allowed_mentions"`
+	Flags      int          `json:"flags,omitempty"`
+}
+
+// embed is a box shown below the content of a message, which is how Discord shows images.
+type embed struct {
+	Title  string       `json:"title,omitempty"`
+	Image  *embedImage  `json:"image,omitempty"`
+	Fields []embedField `json:"fields,omitempty"`
+}
+
+type embedImage struct {
+	URL string `json:"url"`
+}
+
+type embedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+// component is a row of buttons, or a button.
+type component struct {
+	Type       int         `json:"type"`
+	Components []component `json:"components,omitempty"`
+	Style      int         `json:"style,omitempty"`
+	Label      string      `json:"label,omitempty"`
+	CustomID   string      `json:"custom_id,omitempty"`
+}
+
+// reference makes a message a reply to another.
+type reference struct {
+	MessageID string `json:"message_id"`
+}
+
+// allowMention limits who a message notifies to the users it mentions, so that a module quoting
+// @everyone doesn't ping a whole server.
+type allowMention struct {
+	Parse []string `json:"parse"`
+}
+
+// newMessage translates the body and rich content of a message into what Discord displays. Text is
+// used as the content of the message, falling back to the body when there's none, while images and
+// fields become embeds and buttons become components. Discord has no alternative text for images in
+// embeds, so it's dropped.
+func newMessage(body string, content []rich.Block) message {
+	m := message{Embeds: []embed{}, Components: []component{}, Mentions: allowMention{Parse: []string{"users"}}}
+	if len(content) == 0 {
+		m.Content = markdown(body)
+		return m
+	}
+
+	var text []string
+	for _, b := range content {
+		switch b := b.(type) {
+		case rich.Text:
+			text = append(text, markdown(b.Markdown))
+		case rich.Image:
+			m.Embeds = append(m.Embeds, embed{Title: b.Title, Image: &embedImage{URL: b.URL}})
+		case rich.Fields:
+			// Fields are added to an image's embed when they follow one, as they describe it.
+			if len(m.Embeds) == 0 || len(m.Embeds[len(m.Embeds)-1].Fields) > 0 {
+				m.Embeds = append(m.Embeds, embed{})
+			}
+			e := &m.Embeds[len(m.Embeds)-1]
+			for _, f := range b {
+				if len(e.Fields) < maxEmbedFields {
+					e.Fields = append(e.Fields, field(f))
+				}
+			}
+		case rich.Buttons:
+			for len(b) > 0 && len(m.Components) < maxButtonRows {
+				n := len(b)
+				if n > maxRowButtons {
+					n = maxRowButtons
+				}
+				row := component{Type: componentRow}
+				for _, btn := range b[:n] {
+					row.Components = append(row.Components, component{
+						Type:     componentButton,
+						Style:    buttonPrimary,
+						Label:    btn.Label,
+						CustomID: customID(btn.ActionID, btn.Value),
+					})
+				}
+				m.Components = append(m.Components, row)
+				b = b[n:]
+			}
+		}
+	}
+	if len(m.Embeds) > maxEmbeds {
+		m.Embeds = m.Embeds[:maxEmbeds]
+	}
+	m.Content = strings.Join(text, "\n")
+	if len(text) == 0 && len(m.Embeds) == 0 {
+		m.Content = markdown(body)
+	}
+	return m
+}
+
+// empty reports whether there's nothing to post.
+func (m message) empty() bool {
+	return strings.TrimSpace(m.Content) == "" && len(m.Embeds) == 0 && len(m.Components) == 0
+}
+
+// split splits a message whose content is too long into several, breaking the content at the last
+// line break that fits, or the last space if a line is too long by itself. Embeds and components are
+// sent with the last message, after the text they follow, and only the first is a reply.
+func (m message) split() []message {
+	var out []message
+	text := m.Content
+	for utf8.RuneCountInString(text) > maxContent {
+		end := 0
+		for i := 0; i < maxContent; i++ {
+			_, size := utf8.DecodeRuneInString(text[end:])
+			end += size
+		}
+		cut := strings.LastIndexByte(text[:end], '\n')
+		if cut <= 0 {
+			cut = strings.LastIndexByte(text[:end], ' ')
+		}
+		if cut <= 0 {
+			cut = end
+		}
+		part := m
+		part.Content = strings.TrimRight(text[:cut], " \n")
+		part.Embeds, part.Components = []embed{}, []component{}
+		out = append(out, part)
+		m.Reference = nil
+		text = strings.TrimLeft(text[cut:], " \n")
+	}
+	m.Content = text
+	return append(out, m)
+}
+
+// field turns the markdown of a field into an embed field, named after its first line. Fields
+// without a line to spare are left unnamed.
+func field(text string) embedField {
+	text = strings.TrimPrefix(strings.TrimPrefix(text, ">>>"), ">")
+	parts := strings.SplitN(text, "\n", 2)
+	if len(parts) < 2 {
+		return embedField{Name: "\u200b", Value: markdown(text), Inline: true}
+	}
+	return embedField{Name: markdown(parts[0]), Value: markdown(parts[1]), Inline: true}
+}
+
+var (
+	// linkExp matches a link in Slack's markdown, with or without a label.
+	linkExp = regexp.MustCompile(`<((?:https?|mailto):[^|>]+)(?:\|([^>]+))?>`)
+	// mentionExp matches a mention of a user or channel along with its name, which Discord doesn't
+	// understand.
+	mentionExp = regexp.MustCompile(`<([@#][A-Z0-9]+)\|[^>]*>`)
+	// boldExp and strikeExp match text emphasised with a single marker, as Slack does.
+	boldExp   = regexp.MustCompile(`\*([^*\s](?:[^*\n]*[^*\s])?)\*`)
+	strikeExp = regexp.MustCompile(`~([^~\s](?:[^~\n]*[^~\s])?)~`)
+	// quoteExp matches a quote marker at the start of a line, which Discord wants a space after.
+	quoteExp = regexp.MustCompile(`(?m)^(>>>|>)([^ \n>])`)
+)
+
+// markdown converts Slack's markdown, which modules write, into Discord's. Code is left as it is.
+func markdown(text string) string {
+	parts := strings.Split(text, "`")
+	for i := 0; i < len(parts); i += 2 {
+		s := parts[i]
+		s = linkExp.ReplaceAllStringFunc(s, func(l string) string {
+			m := linkExp.FindStringSubmatch(l)
+			if m[2] == "" {
+				return m[1]
+			}
+			return "[" + m[2] + "](" + m[1] + ")"
+		})
+		s = mentionExp.ReplaceAllString(s, "<$1>")
+		s = boldExp.ReplaceAllString(s, "**$1**")
+		s = strikeExp.ReplaceAllString(s, "~~$1~~")
+		s = quoteExp.ReplaceAllString(s, "$1 $2")
+		parts[i] = s
+	}
+	return strings.Join(parts, "`")
+}
@@ -0,0 +1,59 @@
+package gobot
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spy16/snowman"
+)
+
+type emitterKey struct{}
+
+// emitter posts the messages an action emits while it runs.
+type emitter struct {
+	ui   snowman.UI
+	user snowman.User
+	seed int64
+}
+
+// withEmitter returns a copy of ctx carrying an emitter which posts to ui.
+func withEmitter(ctx context.Context, e *emitter) context.Context {
+	return context.WithValue(ctx, emitterKey{}, e)
+}
+
+// Emit posts messages straight away, in order, while an action is still running. Together with
+// Pause this lets an action reply with any number of messages spread out over time; the message it
+// returns, if any, is posted last. Messages are posted the same way as replies, so need their
+// attributes set just like them.
+func Emit(ctx context.Context, msgs ...snowman.Msg) error {
+	e, ok := ctx.Value(emitterKey{}).(*emitter)
+	if !ok || e.ui == nil {
+		return fmt.Errorf("unable to emit message: no UI to post to")
+	}
+	for _, msg := range msgs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if msg.Attribs != nil {
+			msg.Attribs[SeedKey] = e.seed
+		}
+		if err := e.ui.Say(ctx, e.user, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Pause waits for d before an action carries on emitting messages, returning early with an error if
+// ctx is cancelled. Requests are handled one at a time, so keep pauses short.
+func Pause(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,59 @@
+package gobot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spy16/snowman"
+)
+
+// recordingUI is a snowman.UI which records the body of everything said.
+type recordingUI struct {
+	said []string
+}
+
+func (ui *recordingUI) Listen(context.Context) (<-chan snowman.Msg, error) { return nil, nil }
+
+func (ui *recordingUI) Say(_ context.Context, _ snowman.User, msg snowman.Msg) error {
+	ui.said = append(ui.said, msg.Body)
+	return nil
+}
+
+func TestEmit(t *testing.T) {
+	ui := &recordingUI{}
+	pp := NewProcessor()
+	pp.SetUI(ui)
+	joke := func(ctx context.Context, _ snowman.Intent) (snowman.Msg, error) {
+		if err := Emit(ctx, snowman.Msg{Body: "knock knock"}, snowman.Msg{Body: "who's there?"}); err != nil {
+			return snowman.Msg{}, err
+		}
+		if err := Pause(ctx, time.Millisecond); err != nil {
+			return snowman.Msg{}, err
+		}
+		return snowman.Msg{Body: "interrupting cow"}, nil
+	}
+	if err := pp.Register("joke", joke); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := pp.Process(context.Background(), snowman.Intent{ID: "joke"})
+	if err != nil || msg.Body != "interrupting cow" {
+		t.Fatalf("Process(joke) = %q, %v", msg.Body, err)
+	}
+	if len(ui.said) != 2 || ui.said[0] != "knock knock" || ui.said[1] != "who's there?" {
+		t.Errorf("emitted %q, want knock knock then who's there?", ui.said)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := pp.Process(ctx, snowman.Intent{ID: "joke"}); err == nil {
+		t.Errorf("expected emitting to stop once the bot is cancelled")
+	}
+	if err := Pause(ctx, time.Hour); err == nil {
+		t.Errorf("expected a pause to end once the bot is cancelled")
+	}
+	if err := Emit(context.Background(), snowman.Msg{Body: "hi"}); err == nil {
+		t.Errorf("expected emitting outside of an action to fail")
+	}
+}
@@ -0,0 +1,394 @@
+// Package gobottest provides a fake snowman.UI and a small conversation DSL for testing modules end
+// to end without connecting to Slack.
+package gobottest
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot"
+	"github.com/mattikus/gobot/internal/gobot/attr"
+	"github.com/mattikus/gobot/internal/gobot/rich"
+)
+
+// Seed is the seed of the random source used by every conversation, so that tests see the same
+// random outcomes on every run.
+var Seed int64 = 1
+
+// Timeout is how long Expect waits for the bot to say something before failing the test.
+var Timeout = 2 * time.Second
+
+// DefaultChannel is the channel users speak in unless told otherwise.
+const DefaultChannel = "CGENERAL"
+
+// syncIntent is registered ahead of every module so that the conversation can tell when the bot
+// has finished handling everything sent before it.
+const (
+	syncIntent = "gobottest.sync"
+	syncPrefix = "\x00gobottest-sync"
+)
+
+// UI is a snowman.UI which receives messages injected by a test and records everything the bot
+// says instead of posting it.
+type UI struct {
+	in   chan snowman.Msg
+	said chan snowman.Msg
+
+	mu  sync.Mutex
+	log []snowman.Msg
+	ts  int
+}
+
+// NewUI returns a pointer to an empty UI.
+func NewUI() *UI {
+	return &UI{
+		in:   make(chan snowman.Msg),
+		said: make(chan snowman.Msg, 1024),
+	}
+}
+
+// Listen implements snowman.UI. Injected messages are delivered on the returned channel until ctx
+// is cancelled.
+func (ui *UI) Listen(ctx context.Context) (<-chan snowman.Msg, error) {
+	out := make(chan snowman.Msg)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-ui.in:
+				select {
+				case <-ctx.Done():
+					return
+				case out <- msg:
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Say implements snowman.UI. Like a real UI, messages without a body or rich content aren't posted. Each
+// message posted is given a timestamp, available from TimeStamp, in the same way Slack would, and
+// DMs are posted to the user's DMChannel.
+func (ui *UI) Say(_ context.Context, _ snowman.User, msg snowman.Msg) error {
+	if to, ok := msg.Attribs[attr.DMUser].(string); ok && to != "" {
+		msg.Attribs[attr.Channel] = "D" + to
+	}
+	if msg.Body == "" && len(rich.Blocks(msg)) == 0 {
+		return nil
+	}
+	if !strings.HasPrefix(msg.Body, syncPrefix) {
+		ui.mu.Lock()
+		ui.ts++
+		msg.Attribs["gobottest_ts"] = fmt.Sprintf("%d.000000", ui.ts)
+		ui.log = append(ui.log, msg)
+		ui.mu.Unlock()
+	}
+	ui.said <- msg
+	return nil
+}
+
+// Inject delivers a message to the bot as if a user had sent it.
+func (ui *UI) Inject(ctx context.Context, msg snowman.Msg) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case ui.in <- msg:
+		return nil
+	}
+}
+
+// Said returns everything the bot has said so far, in order.
+func (ui *UI) Said() []snowman.Msg {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	return append([]snowman.Msg(nil), ui.log...)
+}
+
+// NewMsg builds a message the way a UI would for a user speaking in a channel, or in a thread
+// within it when thread is set. Channels starting with a D are treated as DMs.
+func NewMsg(user snowman.User, channel, thread, text string, toBot bool) snowman.Msg {
+	dm := strings.HasPrefix(channel, "D")
+	return snowman.Msg{
+		From: user,
+		Body: text,
+		Attribs: map[string]interface{}{
+			attr.Channel: channel,
+			attr.ID:      fmt.Sprintf("%d.000000", time.Now().UnixNano()),
+			attr.Thread:  thread,
+			attr.DM:      dm,
+			attr.ToBot:   toBot || dm,
+		},
+	}
+}
+
+// RegisterFunc wires modules into the classifier and processor used by a conversation.
+type RegisterFunc func(c *gobot.Classifier, pp *gobot.Processor, ui snowman.UI) error
+
+// Conversation runs a bot against a UI and lets a test script what users say and what the bot is
+// expected to reply.
+type Conversation struct {
+	t    testing.TB
+	ui   *UI
+	ctx  context.Context
+	sync int
+}
+
+// Start runs a bot with the modules wired up by register until the test finishes.
+func Start(t testing.TB, register RegisterFunc) *Conversation {
+	t.Helper()
+
+	ui := NewUI()
+	c := gobot.NewClassifier(nil)
+	pp := gobot.NewProcessor()
+	pp.Seed(Seed)
+	pp.SetUI(ui)
+	if err := c.Reply("^"+syncPrefix+` \d+$`, syncIntent); err != nil {
+		t.Fatalf("unable to register sync pattern: %v", err)
+	}
+	if err := pp.Register(syncIntent, func(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+		return snowman.Msg{Body: intent.Msg.Body}, nil
+	}); err != nil {
+		t.Fatalf("unable to register sync intent: %v", err)
+	}
+	if err := register(c, pp, ui); err != nil {
+		t.Fatalf("unable to register modules: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		snowman.Run(ctx,
+			snowman.WithName("gobot"),
+			snowman.WithLogger(snowman.NoOpLogger{}),
+			snowman.WithUI(ui),
+			snowman.WithClassifier(c),
+			snowman.WithProcessor(pp),
+		)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return &Conversation{t: t, ui: ui, ctx: ctx}
+}
+
+// UI returns the fake UI the conversation is running against.
+func (c *Conversation) UI() *UI { return c.ui }
+
+// User returns a user who can take part in the conversation. The user's ID is derived from their
+// name, and they speak in DefaultChannel until moved with In.
+func (c *Conversation) User(name string) *Speaker {
+	return &Speaker{
+		c:       c,
+		user:    snowman.User{ID: "U" + strings.ToUpper(name), Name: name},
+		channel: DefaultChannel,
+	}
+}
+
+// Expect waits for the next thing the bot says and fails the test unless its body matches pattern.
+func (c *Conversation) Expect(pattern string) snowman.Msg {
+	c.t.Helper()
+	re := regexp.MustCompile(pattern)
+	select {
+	case msg := <-c.ui.said:
+		if !re.MatchString(msg.Body) {
+			c.t.Fatalf("expected bot to say something matching %q, got %q", pattern, msg.Body)
+		}
+		return msg
+	case <-time.After(Timeout):
+		c.t.Fatalf("timed out waiting for bot to say something matching %q", pattern)
+	}
+	return snowman.Msg{}
+}
+
+// ExpectIn is like Expect but also checks which channel the message was posted to.
+func (c *Conversation) ExpectIn(channel, pattern string) snowman.Msg {
+	c.t.Helper()
+	msg := c.Expect(pattern)
+	if got := Channel(msg); got != channel {
+		c.t.Fatalf("expected %q to be posted in %q, got %q", msg.Body, channel, got)
+	}
+	return msg
+}
+
+// ExpectNothing fails the test if the bot says anything before it has finished handling every
+// message sent so far.
+func (c *Conversation) ExpectNothing() {
+	c.t.Helper()
+	c.sync++
+	marker := fmt.Sprintf("%v %d", syncPrefix, c.sync)
+	if err := c.ui.Inject(c.ctx, NewMsg(snowman.User{ID: "USYNC"}, DefaultChannel, "", marker, true)); err != nil {
+		c.t.Fatalf("unable to sync with bot: %v", err)
+	}
+	select {
+	case msg := <-c.ui.said:
+		if msg.Body != marker {
+			c.t.Fatalf("expected bot to say nothing, got %q", msg.Body)
+		}
+	case <-time.After(Timeout):
+		c.t.Fatalf("timed out syncing with bot")
+	}
+}
+
+// Channel returns the channel a message from the bot was posted to.
+func Channel(msg snowman.Msg) string {
+	channel, _ := msg.Attribs[attr.Channel].(string)
+	return channel
+}
+
+// TimeStamp returns the timestamp a message from the bot was posted with.
+func TimeStamp(msg snowman.Msg) string {
+	ts, _ := msg.Attribs["gobottest_ts"].(string)
+	return ts
+}
+
+// Updated returns the timestamp of the message a message from the bot replaced, if any.
+func Updated(msg snowman.Msg) string {
+	ts, _ := msg.Attribs[attr.Update].(string)
+	return ts
+}
+
+// Ephemeral returns the user a message from the bot was only visible to, if any.
+func Ephemeral(msg snowman.Msg) string {
+	user, _ := msg.Attribs[attr.Ephemeral].(string)
+	return user
+}
+
+// Thread returns the timestamp of the thread a message from the bot was posted in, if any.
+func Thread(msg snowman.Msg) string {
+	thread, _ := msg.Attribs[attr.Thread].(string)
+	return thread
+}
+
+// Speaker is a user taking part in a conversation.
+type Speaker struct {
+	c       *Conversation
+	user    snowman.User
+	channel string
+	thread  string
+}
+
+// User returns the snowman.User messages are sent from.
+func (s *Speaker) User() snowman.User { return s.user }
+
+// Mention returns how the user is mentioned in a message.
+func (s *Speaker) Mention() string { return rich.Mention(s.user.ID) }
+
+// DMChannel returns the channel used for the user's DMs with the bot.
+func (s *Speaker) DMChannel() string { return "D" + s.user.ID }
+
+// In returns a copy of the speaker who talks in the given channel.
+func (s *Speaker) In(channel string) *Speaker {
+	out := *s
+	out.channel = channel
+	out.thread = ""
+	return &out
+}
+
+// InThread returns a copy of the speaker who talks in the thread with the given timestamp.
+func (s *Speaker) InThread(thread string) *Speaker {
+	out := *s
+	out.thread = thread
+	return &out
+}
+
+// Says sends a message to the channel without addressing the bot.
+func (s *Speaker) Says(text string) *Conversation {
+	return s.send(s.channel, s.thread, text, false)
+}
+
+// Tells sends a message to the channel addressed to the bot.
+func (s *Speaker) Tells(text string) *Conversation {
+	return s.send(s.channel, s.thread, text, true)
+}
+
+// DMs sends a direct message to the bot.
+func (s *Speaker) DMs(text string) *Conversation {
+	return s.send(s.DMChannel(), "", text, true)
+}
+
+// Slash runs the bot's slash command with the given text, as in `/gobot text`.
+func (s *Speaker) Slash(text string) *Conversation {
+	s.c.t.Helper()
+	msg := NewMsg(s.user, s.channel, "", text, true)
+	msg.Attribs[attr.Response] = "https://gobottest.invalid/commands/" + s.user.ID
+	if err := s.c.ui.Inject(s.c.ctx, msg); err != nil {
+		s.c.t.Fatalf("unable to run slash command %q: %v", text, err)
+	}
+	return s.c
+}
+
+// Reacts adds an emoji reaction, named without colons, to the message with the given timestamp
+// which author sent in the speaker's channel.
+func (s *Speaker) Reacts(author snowman.User, ts, reaction string) *Conversation {
+	return s.react(author, ts, reaction, false)
+}
+
+// Unreacts removes an emoji reaction, named without colons, from the message with the given
+// timestamp which author sent in the speaker's channel.
+func (s *Speaker) Unreacts(author snowman.User, ts, reaction string) *Conversation {
+	return s.react(author, ts, reaction, true)
+}
+
+func (s *Speaker) react(author snowman.User, ts, reaction string, removed bool) *Conversation {
+	s.c.t.Helper()
+	msg := snowman.Msg{
+		From: s.user,
+		Body: ":" + reaction + ":",
+		Attribs: map[string]interface{}{
+			attr.Channel:         s.channel,
+			attr.ID:              ts,
+			attr.Author:          author.ID,
+			attr.DM:              strings.HasPrefix(s.channel, "D"),
+			attr.Reaction:        reaction,
+			attr.ReactionRemoved: removed,
+			attr.ToBot:           false,
+		},
+	}
+	if err := s.c.ui.Inject(s.c.ctx, msg); err != nil {
+		s.c.t.Fatalf("unable to react with %q: %v", reaction, err)
+	}
+	return s.c
+}
+
+// Clicks interacts with the element with the given action ID in a message from the bot, such as by
+// clicking a button.
+func (s *Speaker) Clicks(msg snowman.Msg, actionID, value string) *Conversation {
+	s.c.t.Helper()
+	click := snowman.Msg{
+		From: s.user,
+		Body: actionID,
+		Attribs: map[string]interface{}{
+			attr.Channel:     Channel(msg),
+			attr.ID:          TimeStamp(msg),
+			attr.Thread:      Thread(msg),
+			attr.DM:          strings.HasPrefix(Channel(msg), "D"),
+			attr.Action:      actionID,
+			attr.ActionValue: value,
+			attr.ToBot:       true,
+		},
+	}
+	if err := s.c.ui.Inject(s.c.ctx, click); err != nil {
+		s.c.t.Fatalf("unable to click %q: %v", actionID, err)
+	}
+	return s.c
+}
+
+func (s *Speaker) send(channel, thread, text string, toBot bool) *Conversation {
+	s.c.t.Helper()
+	if err := s.c.ui.Inject(s.c.ctx, NewMsg(s.user, channel, thread, text, toBot)); err != nil {
+		s.c.t.Fatalf("unable to send %q: %v", text, err)
+	}
+	return s.c
+}
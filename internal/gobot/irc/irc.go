@@ -0,0 +1,428 @@
+// Package irc provides a snowman.UI which connects to an IRC server. Messages are given the
+// attributes described by package attr, so that modules work on it without changes, and rich
+// content is sent as plain text.
+//
+// Anyone can use any nick that's free, so users are identified by the services account they're
+// logged in to, which servers supporting the account-tag capability tag their messages with.
+// Users who aren't logged in are identified by their nick prefixed with a tilde, as in "~alice",
+// and marked as unverified so that they're never trusted with a role.
+package irc
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot/attr"
+	"github.com/mattikus/gobot/internal/gobot/rich"
+)
+
+// reconnectDelay is how long to wait before connecting again after the previous connection failed.
+const reconnectDelay = 5 * time.Second
+
+// Default flood protection, which lets a few lines through at once and then one every couple of
+// seconds, as most servers allow.
+const (
+	defaultFloodBurst = 5
+	defaultFloodDelay = 2 * time.Second
+)
+
+// Config is how to connect to an IRC server.
+type Config struct {
+	// Server is the host and port of the server, as in "irc.libera.chat:6697".
+	Server string
+	// TLS connects to the server over TLS.
+	TLS bool
+	// Nick is the nick the bot asks for. An underscore is added while it's taken.
+	Nick string
+	// SASLUser and SASLPassword authenticate with SASL PLAIN while connecting, if set.
+	SASLUser     string
+	SASLPassword string
+	// NickServPassword identifies with NickServ once connected, if set.
+	NickServPassword string
+	// Channels are joined once connected.
+	Channels []string
+	// FloodBurst lines can be sent at once, after which one line is sent every FloodDelay.
+	FloodBurst int
+	FloodDelay time.Duration
+}
+
+// New returns an IRC UI which connects to a server as configured.
+func New(cfg Config, logger logger) *IRC {
+	if logger == nil {
+		logger = snowman.NoOpLogger{}
+	}
+	if cfg.FloodBurst <= 0 {
+		cfg.FloodBurst = defaultFloodBurst
+	}
+	if cfg.FloodDelay <= 0 {
+		cfg.FloodDelay = defaultFloodDelay
+	}
+	return &IRC{
+		cfg:    cfg,
+		dialer: &net.Dialer{Timeout: 30 * time.Second},
+		logger: logger,
+	}
+}
+
+// IRC implements snowman UI using the IRC client protocol.
+type IRC struct {
+	logger
+
+	cfg       Config
+	dialer    *net.Dialer
+	tlsConfig *tls.Config
+
+	// mu guards the connection, which is replaced whenever the bot reconnects.
+	mu   sync.Mutex
+	conn net.Conn
+	nick string
+
+	// flood guards when the next line may be sent, holding it while waiting so that lines are sent
+	// in order.
+	flood sync.Mutex
+	next  time.Time
+
+	// ts numbers the messages received, which stand in for Slack's timestamps.
+	ts int
+	// ids and nicks map the nicks of the users who have spoken to their user IDs and back, so that
+	// mentions can be translated between the two.
+	ids   map[string]string
+	nicks map[string]string
+}
+
+// Listen connects to the server and starts listening for messages, reconnecting whenever the
+// connection drops, until ctx is cancelled. Messages are pushed to the returned channel.
+func (irc *IRC) Listen(ctx context.Context) (<-chan snowman.Msg, error) {
+	if irc.cfg.Server == "" || irc.cfg.Nick == "" {
+		return nil, fmt.Errorf("irc: server and nick must be set")
+	}
+	out := make(chan snowman.Msg)
+	go irc.listen(ctx, out)
+	return out, nil
+}
+
+func (irc *IRC) listen(ctx context.Context, out chan<- snowman.Msg) {
+	defer close(out)
+
+	for {
+		err := irc.run(ctx, out)
+		if ctx.Err() != nil {
+			return
+		}
+		irc.Errorf("irc connection failed: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+// dial opens a connection to the server, over TLS if configured.
+func (irc *IRC) dial(ctx context.Context) (net.Conn, error) {
+	if !irc.cfg.TLS {
+		return irc.dialer.DialContext(ctx, "tcp", irc.cfg.Server)
+	}
+	conf := irc.tlsConfig
+	if conf == nil {
+		host, _, err := net.SplitHostPort(irc.cfg.Server)
+		if err != nil {
+			return nil, err
+		}
+		conf = &tls.Config{ServerName: host}
+	}
+	d := &tls.Dialer{NetDialer: irc.dialer, Config: conf}
+	return d.DialContext(ctx, "tcp", irc.cfg.Server)
+}
+
+// run opens a single connection, registers with the server and processes what it sends until the
+// connection is closed.
+func (irc *IRC) run(ctx context.Context, out chan<- snowman.Msg) error {
+	conn, err := irc.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to connect to %v: %w", irc.cfg.Server, err)
+	}
+	defer conn.Close()
+	irc.mu.Lock()
+	irc.conn, irc.nick = conn, irc.cfg.Nick
+	irc.ids, irc.nicks = map[string]string{}, map[string]string{}
+	irc.mu.Unlock()
+	defer func() {
+		irc.mu.Lock()
+		irc.conn = nil
+		irc.mu.Unlock()
+	}()
+
+	// Reads block forever, so unblock them by closing the connection once we're cancelled.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			irc.send("QUIT")
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	// Capabilities are negotiated before registering, asking for messages to be tagged with the
+	// account of whoever sent them and for SASL if configured. Servers which don't support them
+	// ignore the request.
+	sasl := irc.cfg.SASLUser != ""
+	irc.send("CAP LS 302")
+	irc.send("NICK " + irc.cfg.Nick)
+	irc.send("USER " + irc.cfg.Nick + " 0 * :" + irc.cfg.Nick)
+
+	var offered []string
+	registered := false
+	lines := bufio.NewScanner(conn)
+	for lines.Scan() {
+		m := parse(lines.Text())
+		irc.Debugf("irc: %v", lines.Text())
+		switch m.command {
+		case "PING":
+			irc.send("PONG :" + m.param(0))
+		case "ERROR":
+			return fmt.Errorf("server closed the connection: %v", m.param(0))
+		case "CAP":
+			switch m.param(1) {
+			case "LS":
+				// Capabilities may be listed over several lines, all but the last marked with "*".
+				offered = append(offered, strings.Fields(m.param(len(m.params)-1))...)
+				if m.param(2) == "*" {
+					break
+				}
+				req, err := request(offered, sasl)
+				if err != nil {
+					return err
+				}
+				if len(req) == 0 {
+					irc.send("CAP END")
+				} else {
+					irc.send("CAP REQ :" + strings.Join(req, " "))
+				}
+			case "ACK":
+				if sasl {
+					irc.send("AUTHENTICATE PLAIN")
+				} else {
+					irc.send("CAP END")
+				}
+			case "NAK":
+				return fmt.Errorf("server refused capabilities: %v", m.param(2))
+			}
+		case "AUTHENTICATE":
+			if m.param(0) == "+" {
+				creds := irc.cfg.SASLUser + "\x00" + irc.cfg.SASLUser + "\x00" + irc.cfg.SASLPassword
+				irc.send("AUTHENTICATE " + base64.StdEncoding.EncodeToString([]byte(creds)))
+			}
+		case "903":
+			irc.send("CAP END")
+		case "902", "904", "905", "906":
+			return fmt.Errorf("SASL authentication failed: %v", m.param(len(m.params)-1))
+		case "433":
+			// Our nick is taken, so ask for another one until we've registered. After that, the
+			// server keeps the one we have.
+			if !registered {
+				irc.mu.Lock()
+				irc.nick += "_"
+				nick := irc.nick
+				irc.mu.Unlock()
+				irc.send("NICK " + nick)
+			}
+		case "001":
+			registered = true
+			irc.mu.Lock()
+			irc.nick = m.param(0)
+			irc.mu.Unlock()
+			if irc.cfg.NickServPassword != "" {
+				irc.send("PRIVMSG NickServ :IDENTIFY " + irc.cfg.NickServPassword)
+			}
+			for _, ch := range irc.cfg.Channels {
+				irc.send("JOIN " + ch)
+			}
+			irc.Infof("connected to %v as %v", irc.cfg.Server, m.param(0))
+		case "NICK":
+			irc.mu.Lock()
+			if strings.EqualFold(m.nick(), irc.nick) {
+				irc.nick = m.param(0)
+			}
+			irc.mu.Unlock()
+		case "PRIVMSG":
+			irc.handleMessage(ctx, m, out)
+		}
+	}
+	if err := lines.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("connection closed")
+}
+
+// request returns the capabilities to ask for out of those offered, which need to include SASL if
+// it's to be used.
+func request(offered []string, sasl bool) ([]string, error) {
+	has := map[string]bool{}
+	for _, c := range offered {
+		// Values, as in "sasl=PLAIN,EXTERNAL", say more about the capability than we need to know.
+		has[strings.SplitN(c, "=", 2)[0]] = true
+	}
+	var req []string
+	if has["account-tag"] {
+		req = append(req, "account-tag")
+	}
+	if sasl {
+		if !has["sasl"] {
+			return nil, fmt.Errorf("server doesn't support SASL")
+		}
+		req = append(req, "sasl")
+	}
+	return req, nil
+}
+
+// send writes a line to the server straight away. It's used for the protocol, while what the bot
+// says is subject to flood protection.
+func (irc *IRC) send(line string) error {
+	irc.mu.Lock()
+	defer irc.mu.Unlock()
+	if irc.conn == nil {
+		return fmt.Errorf("not connected")
+	}
+	_, err := irc.conn.Write([]byte(line + "\r\n"))
+	return err
+}
+
+// sendLimited writes a line to the server once flood protection allows it. Every line sent adds
+// FloodDelay to a timer, and lines have to wait once it's FloodBurst lines ahead of the clock.
+func (irc *IRC) sendLimited(ctx context.Context, line string) error {
+	irc.flood.Lock()
+	defer irc.flood.Unlock()
+	now := time.Now()
+	if irc.next.Before(now) {
+		irc.next = now
+	}
+	wait := irc.next.Sub(now) - time.Duration(irc.cfg.FloodBurst-1)*irc.cfg.FloodDelay
+	irc.next = irc.next.Add(irc.cfg.FloodDelay)
+	if wait > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	return irc.send(line)
+}
+
+// handleMessage turns a PRIVMSG into a message for the bot. Messages sent to the bot rather than a
+// channel are DMs, in a channel named after whoever sent them.
+func (irc *IRC) handleMessage(ctx context.Context, m line, out chan<- snowman.Msg) {
+	from, target, text := m.nick(), m.param(0), m.param(1)
+	id, verified := "~"+from, false
+	if account := m.tags["account"]; account != "" {
+		id, verified = account, true
+	}
+
+	irc.mu.Lock()
+	self := irc.nick
+	irc.ts++
+	ts := strconv.Itoa(irc.ts)
+	irc.ids[strings.ToLower(from)] = id
+	irc.nicks[id] = from
+	irc.mu.Unlock()
+
+	if strings.EqualFold(from, self) || strings.HasPrefix(text, "\x01") {
+		return
+	}
+	text, tagged := addressed(self, text)
+	text = irc.mentions(text)
+	dm := strings.EqualFold(target, self)
+	if dm {
+		target = from
+	}
+
+	snowMsg := snowman.Msg{
+		From: snowman.User{
+			ID:   id,
+			Name: from,
+		},
+		Body: text,
+		Attribs: map[string]interface{}{
+			attr.Channel:    target,
+			attr.ID:         ts,
+			attr.Thread:     "",
+			attr.DM:         dm,
+			attr.Unverified: !verified,
+			attr.ToBot:      dm || tagged,
+		},
+	}
+
+	select {
+	case <-ctx.Done():
+	case out <- snowMsg:
+	}
+}
+
+// nickMentionExp matches a nick mentioned as in "@alice", up to any punctuation following it.
+var nickMentionExp = regexp.MustCompile(`(^|[\s(])@([^\s@:;,.!?()<>]+)`)
+
+// mentions replaces the nicks mentioned in text with mentions of the users with them, for those
+// who have spoken since connecting.
+func (irc *IRC) mentions(text string) string {
+	irc.mu.Lock()
+	defer irc.mu.Unlock()
+	return nickMentionExp.ReplaceAllStringFunc(text, func(s string) string {
+		m := nickMentionExp.FindStringSubmatch(s)
+		id, ok := irc.ids[strings.ToLower(m[2])]
+		if !ok {
+			return s
+		}
+		return m[1] + rich.Mention(id)
+	})
+}
+
+// nickOf returns the nick of the user with the given ID, as last seen.
+func (irc *IRC) nickOf(id string) string {
+	irc.mu.Lock()
+	defer irc.mu.Unlock()
+	if nick, ok := irc.nicks[id]; ok {
+		return nick
+	}
+	return strings.TrimPrefix(id, "~")
+}
+
+// addressed reports whether text starts with the bot's nick, as in "gobot: card me", and returns
+// the rest of it.
+func addressed(nick, text string) (string, bool) {
+	if len(text) <= len(nick) || !strings.EqualFold(text[:len(nick)], nick) {
+		return text, false
+	}
+	rest := text[len(nick):]
+	switch rest[0] {
+	case ':', ',', ' ':
+		return strings.TrimLeft(rest[1:], " "), true
+	}
+	return text, false
+}
+
+// Nick returns the nick the bot currently has.
+func (irc *IRC) Nick() string {
+	irc.mu.Lock()
+	defer irc.mu.Unlock()
+	return irc.nick
+}
+
+type logger interface {
+	Debugf(msg string, args ...interface{})
+	Infof(msg string, args ...interface{})
+	Warnf(msg string, args ...interface{})
+	Errorf(msg string, args ...interface{})
+}
@@ -0,0 +1,320 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot/attr"
+	"github.com/mattikus/gobot/internal/gobot/rich"
+)
+
+// fakeServer is an IRC server which accepts a single client, records every line it sends and
+// answers just enough of them for it to register.
+type fakeServer struct {
+	net.Listener
+	// taken is a nick which is already in use.
+	taken string
+	lines chan string
+	conn  chan net.Conn
+}
+
+func newFakeServer(t *testing.T, conf *tls.Config, taken string) *fakeServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf != nil {
+		l = tls.NewListener(l, conf)
+	}
+	f := &fakeServer{Listener: l, taken: taken, lines: make(chan string, 64), conn: make(chan net.Conn, 1)}
+	t.Cleanup(func() { l.Close() })
+	go f.serve()
+	return f
+}
+
+func (f *fakeServer) serve() {
+	conn, err := f.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	f.conn <- conn
+	send := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	var nick, user string
+	negotiating := false
+	lines := bufio.NewScanner(conn)
+	for lines.Scan() {
+		l := parse(lines.Text())
+		f.lines <- lines.Text()
+		switch l.command {
+		case "CAP":
+			switch l.param(0) {
+			case "LS":
+				negotiating = true
+				send(":irc.test CAP * LS * :multi-prefix sasl=PLAIN,EXTERNAL")
+				send(":irc.test CAP * LS :account-tag")
+			case "REQ":
+				send(":irc.test CAP * ACK :" + l.param(1))
+			case "END":
+				negotiating = false
+			}
+		case "AUTHENTICATE":
+			if l.param(0) == "PLAIN" {
+				send("AUTHENTICATE +")
+			} else if creds, _ := base64.StdEncoding.DecodeString(l.param(0)); string(creds) == "gobot\x00gobot\x00hunter2" {
+				send(":irc.test 903 * :SASL authentication successful")
+			} else {
+				send(":irc.test 904 * :SASL authentication failed")
+			}
+		case "NICK":
+			if l.param(0) == f.taken {
+				send(":irc.test 433 * " + l.param(0) + " :Nickname is already in use")
+			} else {
+				nick = l.param(0)
+			}
+		case "USER":
+			user = l.param(0)
+		}
+		if nick != "" && user != "" && !negotiating {
+			send(":irc.test 001 " + nick + " :Welcome")
+			user = ""
+		}
+	}
+}
+
+// expect checks the client sends the given lines next, in order.
+func (f *fakeServer) expect(t *testing.T, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case got := <-f.lines:
+			if got != w {
+				t.Fatalf("client sent %q, want %q", got, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for client to send %q", w)
+		}
+	}
+}
+
+// connect starts an IRC UI listening to the fake server and waits for it to register.
+func connect(t *testing.T, cfg Config) (*IRC, *fakeServer, <-chan snowman.Msg, net.Conn) {
+	f := newFakeServer(t, nil, "")
+	cfg.Server, cfg.Nick, cfg.Channels = f.Addr().String(), "gobot", []string{"#a"}
+	irc := New(cfg, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	msgs, err := irc.Listen(ctx)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	f.expect(t, "CAP LS 302", "NICK gobot", "USER gobot 0 * :gobot", "CAP REQ :account-tag", "CAP END", "JOIN #a")
+	return irc, f, msgs, <-f.conn
+}
+
+// selfSigned returns TLS configs for a server with a self-signed certificate, and a client which
+// trusts it.
+func selfSigned(t *testing.T) (server, client *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}},
+		&tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}
+}
+
+func TestRegister(t *testing.T) {
+	serverTLS, clientTLS := selfSigned(t)
+	f := newFakeServer(t, serverTLS, "gobot")
+	irc := New(Config{
+		Server:           f.Addr().String(),
+		TLS:              true,
+		Nick:             "gobot",
+		SASLUser:         "gobot",
+		SASLPassword:     "hunter2",
+		NickServPassword: "hunter3",
+		Channels:         []string{"#a", "#b"},
+	}, nil)
+	irc.tlsConfig = clientTLS
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgs, err := irc.Listen(ctx)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+
+	// The server answers each line as it reads it, so the client has sent all three of the lines it
+	// registers with before it hears which capabilities are available or that its nick is taken.
+	f.expect(t,
+		"CAP LS 302",
+		"NICK gobot",
+		"USER gobot 0 * :gobot",
+		"CAP REQ :account-tag sasl",
+		"NICK gobot_",
+		"AUTHENTICATE PLAIN",
+		"AUTHENTICATE "+base64.StdEncoding.EncodeToString([]byte("gobot\x00gobot\x00hunter2")),
+		"CAP END",
+		"PRIVMSG NickServ :IDENTIFY hunter3",
+		"JOIN #a",
+		"JOIN #b",
+	)
+	if nick := irc.Nick(); nick != "gobot_" {
+		t.Errorf("got nick %q, want gobot_", nick)
+	}
+
+	cancel()
+	f.expect(t, "QUIT")
+	select {
+	case _, ok := <-msgs:
+		if ok {
+			t.Errorf("expected no more messages")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for listener to stop")
+	}
+}
+
+func TestMessages(t *testing.T) {
+	_, f, msgs, conn := connect(t, Config{})
+	for _, l := range []string{
+		"PING :irc.test",
+		":gobot!bot@host PRIVMSG #a :gobot: card me",
+		"@account=alice :alice!a@host PRIVMSG #a :gobot: card me",
+		"@account=alice :alice!a@host PRIVMSG #a :\x01ACTION waves\x01",
+		"@time=now;account=alice :alice!a@host PRIVMSG #a :gobotty is a word",
+		":mallory!m@host PRIVMSG #a :GOBOT, baseball me",
+		"@account=alice :alice!a@host PRIVMSG gobot :karma @mallory and @bob",
+	} {
+		conn.Write([]byte(l + "\r\n"))
+	}
+	f.expect(t, "PONG :irc.test")
+
+	// The bot's own message and the CTCP action are ignored, so the first message is alice's. Users
+	// who aren't logged in are told apart from those who are, and mentioned by their nick.
+	tests := []struct {
+		body    string
+		from    string
+		toBot   bool
+		channel string
+		dm      bool
+	}{
+		{"card me", "alice", true, "#a", false},
+		{"gobotty is a word", "alice", false, "#a", false},
+		{"baseball me", "~mallory", true, "#a", false},
+		{"karma <@~mallory> and @bob", "alice", true, "alice", true},
+	}
+	for _, tt := range tests {
+		select {
+		case msg := <-msgs:
+			if msg.Body != tt.body || msg.From.ID != tt.from || msg.Attribs[attr.ToBot] != tt.toBot {
+				t.Errorf("got message %q from %+v (to_bot=%v), want %q from %v (to_bot=%v)",
+					msg.Body, msg.From, msg.Attribs[attr.ToBot], tt.body, tt.from, tt.toBot)
+			}
+			if unverified := msg.Attribs[attr.Unverified]; unverified != strings.HasPrefix(tt.from, "~") {
+				t.Errorf("%q: got unverified=%v from %v", tt.body, unverified, tt.from)
+			}
+			if msg.Attribs[attr.Channel] != tt.channel || msg.Attribs[attr.DM] != tt.dm {
+				t.Errorf("%q: got message in %v (dm=%v), want %v (dm=%v)", tt.body,
+					msg.Attribs[attr.Channel], msg.Attribs[attr.DM], tt.channel, tt.dm)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %q", tt.body)
+		}
+	}
+}
+
+func TestSay(t *testing.T) {
+	irc, f, msgs, conn := connect(t, Config{FloodBurst: 2, FloodDelay: 30 * time.Millisecond})
+	say := func(body string, attribs map[string]interface{}) {
+		t.Helper()
+		if err := irc.Say(context.Background(), snowman.User{}, snowman.Msg{Body: body, Attribs: attribs}); err != nil {
+			t.Fatalf("Say: %v", err)
+		}
+	}
+
+	// Users are mentioned and messaged by the nick they were last seen with.
+	conn.Write([]byte("@account=alice :Alice2!a@host PRIVMSG #a :hi\r\n"))
+	<-msgs
+
+	start := time.Now()
+	say("*hi* <@alice> and <@~bob>\n\nsee <#C1|general> and <##b>", map[string]interface{}{attr.Channel: "#a"})
+	say("psst", map[string]interface{}{attr.Channel: "#a", attr.Ephemeral: "alice"})
+	say("hello", map[string]interface{}{attr.DMUser: "alice"})
+	f.expect(t,
+		"PRIVMSG #a :\x02hi\x02 Alice2 and bob",
+		"PRIVMSG #a :see #general and #b",
+		"NOTICE Alice2 :psst",
+		"PRIVMSG Alice2 :hello",
+	)
+	// Two lines are let through at once, and the next two each wait for another 30ms.
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("sent 4 lines in %v, want flood protection to slow them down", elapsed)
+	}
+
+	say("fallback", map[string]interface{}{
+		attr.Channel: "#a",
+		rich.Key: []rich.Block{
+			rich.Image{URL: "https://example.com/688.jpg", Alt: "Odood"},
+			rich.Text{Markdown: "<https://example.com|Odood>"},
+			rich.Buttons{{ActionID: "cards.deal_again", Label: "Deal again"}},
+		},
+	})
+	long := strings.Repeat("word ", 100)
+	say(long, map[string]interface{}{attr.Channel: "#a"})
+	f.expect(t,
+		"PRIVMSG #a :[image: Odood https://example.com/688.jpg]",
+		"PRIVMSG #a :Odood (https://example.com)",
+		"PRIVMSG #a :[Deal again](cards.deal_again)",
+		"PRIVMSG #a :"+strings.TrimSpace(long[:400]),
+		"PRIVMSG #a :"+strings.TrimSpace(long[400:]),
+	)
+}
+
+func TestParse(t *testing.T) {
+	l := parse(`@time=now;account=al\sice;+draft :alice!a@host PRIVMSG #a :hi there`)
+	if l.nick() != "alice" || l.command != "PRIVMSG" || l.param(0) != "#a" || l.param(1) != "hi there" || l.param(2) != "" {
+		t.Errorf("got %+v", l)
+	}
+	if l.tags["account"] != "al ice" || l.tags["time"] != "now" || len(l.tags) != 3 {
+		t.Errorf("got tags %v", l.tags)
+	}
+	if l := parse("PING irc.test"); l.command != "PING" || l.param(0) != "irc.test" {
+		t.Errorf("got %+v", l)
+	}
+}
@@ -0,0 +1,175 @@
+package irc
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot/attr"
+	"github.com/mattikus/gobot/internal/gobot/render"
+	"github.com/mattikus/gobot/internal/gobot/rich"
+)
+
+// maxLineBytes is the most text sent in one line. Servers allow 512 bytes for the whole line, and
+// pass on what they're sent prefixed with the bot's nick, user and host, so this leaves room for it.
+const maxLineBytes = 400
+
+// line is a line sent by the server.
+type line struct {
+	tags    map[string]string
+	prefix  string
+	command string
+	params  []string
+}
+
+// parse splits a line into its tags, prefix, command and parameters.
+func parse(s string) line {
+	var l line
+	if strings.HasPrefix(s, "@") {
+		i := strings.IndexByte(s, ' ')
+		if i < 0 {
+			return l
+		}
+		l.tags = parseTags(s[1:i])
+		s = strings.TrimLeft(s[i+1:], " ")
+	}
+	if strings.HasPrefix(s, ":") {
+		i := strings.IndexByte(s, ' ')
+		if i < 0 {
+			return line{prefix: s[1:]}
+		}
+		l.prefix, s = s[1:i], strings.TrimLeft(s[i+1:], " ")
+	}
+	for s != "" {
+		if strings.HasPrefix(s, ":") && l.command != "" {
+			l.params = append(l.params, s[1:])
+			break
+		}
+		i := strings.IndexByte(s, ' ')
+		if i < 0 {
+			i = len(s)
+		}
+		if l.command == "" {
+			l.command = strings.ToUpper(s[:i])
+		} else {
+			l.params = append(l.params, s[:i])
+		}
+		s = strings.TrimLeft(s[i:], " ")
+	}
+	return l
+}
+
+// tagEscapes undoes the escaping of tag values.
+var tagEscapes = strings.NewReplacer(`\:`, ";", `\s`, " ", `\\`, `\`, `\r`, "\r", `\n`, "\n")
+
+// parseTags parses tags written as "a=1;b", where tags without a value are empty.
+func parseTags(s string) map[string]string {
+	tags := map[string]string{}
+	for _, tag := range strings.Split(s, ";") {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) == 2 {
+			tags[kv[0]] = tagEscapes.Replace(kv[1])
+		} else if kv[0] != "" {
+			tags[kv[0]] = ""
+		}
+	}
+	return tags
+}
+
+// param returns the ith parameter, or an empty string if there aren't that many.
+func (l line) param(i int) string {
+	if i < 0 || i >= len(l.params) {
+		return ""
+	}
+	return l.params[i]
+}
+
+// nick returns the nick of whoever sent the line.
+func (l line) nick() string {
+	if i := strings.IndexByte(l.prefix, '!'); i >= 0 {
+		return l.prefix[:i]
+	}
+	return l.prefix
+}
+
+// Say sends a message to the channel or user it's for, a line at a time. Messages for a single
+// user in a channel are sent to them as a notice, and updates are sent as new messages as IRC can't
+// change what was said.
+func (irc *IRC) Say(ctx context.Context, _ snowman.User, msg snowman.Msg) error {
+	command := "PRIVMSG"
+	target, _ := msg.Attribs[attr.Channel].(string)
+	if to, ok := msg.Attribs[attr.Ephemeral].(string); ok && to != "" {
+		command, target = "NOTICE", irc.nickOf(to)
+	}
+	if to, ok := msg.Attribs[attr.DMUser].(string); ok && to != "" {
+		command, target = "PRIVMSG", irc.nickOf(to)
+	}
+	if target == "" {
+		irc.Warnf("unable to get channel from context")
+		return nil
+	}
+
+	for _, l := range lines(irc.plainText(msg)) {
+		if err := irc.sendLimited(ctx, command+" "+target+" :"+l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	// mentionExp matches a mention of a user or channel, with or without a name. Users are
+	// mentioned by their nick, and channel names are their IDs.
+	mentionExp = regexp.MustCompile(`<([@#])([^|>]+)(?:\|([^>]*))?>`)
+	// boldExp matches text emphasised with asterisks.
+	boldExp = regexp.MustCompile(`\*([^*\s](?:[^*\n]*[^*\s])?)\*`)
+)
+
+// plainText returns the text of a message as it's shown on IRC. Rich content is described as text
+// in place of the body, mentions are replaced with who or what they mention, and bold text is sent
+// with IRC's formatting code for it.
+func (irc *IRC) plainText(msg snowman.Msg) string {
+	content := rich.Blocks(msg)
+	if len(content) == 0 {
+		content = []rich.Block{rich.Text{Markdown: msg.Body}}
+	}
+	text := render.Text(content)
+	text = mentionExp.ReplaceAllStringFunc(text, func(s string) string {
+		m := mentionExp.FindStringSubmatch(s)
+		switch {
+		case m[1] == "#" && m[3] != "":
+			return "#" + m[3]
+		case m[1] == "@":
+			return irc.nickOf(m[2])
+		}
+		return m[2]
+	})
+	return boldExp.ReplaceAllString(text, "\x02$1\x02")
+}
+
+// lines splits text into the lines it's sent in, skipping blank ones and breaking any which are too
+// long at the last space that fits.
+func lines(text string) []string {
+	var out []string
+	for _, l := range strings.Split(text, "\n") {
+		l = strings.TrimRight(l, " \r")
+		for len(l) > maxLineBytes {
+			cut := strings.LastIndexByte(l[:maxLineBytes], ' ')
+			if cut <= 0 {
+				cut = maxLineBytes
+				for cut > 0 && !utf8.RuneStart(l[cut]) {
+					cut--
+				}
+			}
+			out = append(out, l[:cut])
+			l = strings.TrimLeft(l[cut:], " ")
+		}
+		if strings.TrimSpace(l) != "" {
+			out = append(out, l)
+		}
+	}
+	return out
+}
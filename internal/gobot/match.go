@@ -0,0 +1,245 @@
+package gobot
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"sort"
+
+	"github.com/spy16/snowman"
+)
+
+// AlsoKey is the intent context key holding the intents of other patterns registered with
+// MultiMatch which matched the same message. The processor runs them after the intent itself.
+const AlsoKey = "also_intents"
+
+// PatternOption configures how a pattern competes with others matching the same message.
+type PatternOption func(*intentPattern)
+
+// Priority ranks a pattern above every pattern with a lower priority, however well they match.
+// Patterns have a priority of 0 unless given another.
+func Priority(n int) PatternOption {
+	return func(p *intentPattern) {
+		p.priority = n
+	}
+}
+
+// MultiMatch lets a hear pattern fire alongside whichever intent wins, instead of competing with the
+// others. It has no effect on reply patterns, as only one reply is made to a message.
+func MultiMatch() PatternOption {
+	return func(p *intentPattern) {
+		p.multi = true
+	}
+}
+
+// intentPattern is a pattern registered for an intent, and what it's ranked by when several match.
+type intentPattern struct {
+	re       *regexp.Regexp
+	id       string
+	priority int
+	// anchors counts whether the pattern is anchored to the start of the message, and to the end.
+	anchors int
+	multi   bool
+	order   int
+}
+
+func newIntentPattern(pattern, intentID string, opts []PatternOption) (intentPattern, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return intentPattern{}, err
+	}
+	p := intentPattern{re: re, id: intentID, anchors: anchors(re)}
+	for _, opt := range opts {
+		opt(&p)
+	}
+	return p, nil
+}
+
+// anchors counts whether a pattern is anchored to the start of the text, and to the end.
+func anchors(re *regexp.Regexp) int {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return 0
+	}
+	var start, end bool
+	var walk func(*syntax.Regexp)
+	walk = func(r *syntax.Regexp) {
+		switch r.Op {
+		case syntax.OpBeginText, syntax.OpBeginLine:
+			start = true
+		case syntax.OpEndText, syntax.OpEndLine:
+			end = true
+		}
+		for _, sub := range r.Sub {
+			walk(sub)
+		}
+	}
+	walk(parsed)
+	n := 0
+	for _, anchored := range []bool{start, end} {
+		if anchored {
+			n++
+		}
+	}
+	return n
+}
+
+// candidate is a registered pattern which matched a message.
+type candidate struct {
+	intentPattern
+	reply bool
+	// specificity is how much of the message the pattern matched outside of named groups, which
+	// capture whatever the user wrote rather than what the pattern spells out.
+	specificity int
+	ctx         map[string]interface{}
+}
+
+// outranks reports whether a candidate beats another. Reply patterns beat hear patterns, then
+// patterns are ranked by priority, how anchored they are, specificity and finally the order they
+// were registered in.
+func (a candidate) outranks(b candidate) bool {
+	switch {
+	case a.reply != b.reply:
+		return a.reply
+	case a.priority != b.priority:
+		return a.priority > b.priority
+	case a.anchors != b.anchors:
+		return a.anchors > b.anchors
+	case a.specificity != b.specificity:
+		return a.specificity > b.specificity
+	default:
+		return a.order < b.order
+	}
+}
+
+func (a candidate) intent() snowman.Intent {
+	return snowman.Intent{ID: a.id, Ctx: a.ctx}
+}
+
+// matchPattern tries to match a message body with a pattern. If it matches, all the named
+// expressions are inserted into the candidate's context.
+func matchPattern(p intentPattern, body string) (candidate, bool) {
+	idx := p.re.FindStringSubmatchIndex(body)
+	if idx == nil {
+		return candidate{}, false
+	}
+	cand := candidate{intentPattern: p, ctx: map[string]interface{}{}}
+	captured := make([]bool, idx[1]-idx[0])
+	for i, name := range p.re.SubexpNames() {
+		start, end := idx[2*i], idx[2*i+1]
+		if start < 0 {
+			cand.ctx[name] = ""
+			continue
+		}
+		cand.ctx[name] = body[start:end]
+		if i > 0 && name != "" {
+			for k := start; k < end; k++ {
+				captured[k-idx[0]] = true
+			}
+		}
+	}
+	for _, c := range captured {
+		if !c {
+			cand.specificity++
+		}
+	}
+	return cand, true
+}
+
+// match returns every pattern matching a message body, best ranked first. Reply patterns are only
+// tried for messages directed to the bot. The caller must hold c.mu.
+func (c *Classifier) match(body string, toBot bool) []candidate {
+	var out []candidate
+	if toBot {
+		for _, p := range c.reply {
+			if cand, ok := matchPattern(p, body); ok {
+				cand.reply = true
+				out = append(out, cand)
+			}
+		}
+	}
+	for _, p := range c.hear {
+		if cand, ok := matchPattern(p, body); ok {
+			out = append(out, cand)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].outranks(out[j])
+	})
+	return out
+}
+
+// winner returns the index of the best ranked candidate which doesn't just fire alongside others,
+// or failing that the best ranked candidate. It returns -1 if there are no candidates.
+func winner(cs []candidate) int {
+	for i, cand := range cs {
+		if !cand.multi {
+			return i
+		}
+	}
+	if len(cs) > 0 {
+		return 0
+	}
+	return -1
+}
+
+// pick returns the intent of the winning candidate, and those of every other candidate registered
+// with MultiMatch. If there are no candidates, it returns SysIntentUnknown.
+func pick(cs []candidate) (snowman.Intent, []snowman.Intent) {
+	win := winner(cs)
+	if win < 0 {
+		return snowman.Intent{ID: snowman.SysIntentUnknown}, nil
+	}
+	var also []snowman.Intent
+	for i, cand := range cs {
+		if i != win && cand.multi {
+			also = append(also, cand.intent())
+		}
+	}
+	return cs[win].intent(), also
+}
+
+// Match describes a pattern which matched a message, as reported by Explain.
+type Match struct {
+	IntentID string
+	Pattern  string
+	// Reply is whether the pattern is only for messages directed to the bot.
+	Reply    bool
+	Priority int
+	// Anchors counts whether the pattern is anchored to the start of the message, and to the end.
+	Anchors int
+	// Specificity is how much of the message the pattern matched outside of named groups.
+	Specificity int
+	// Order is the order the pattern was registered in, which breaks any remaining ties.
+	Order int
+	// Multi is whether the pattern fires alongside the winner, rather than competing with it.
+	Multi bool
+	// Won is whether the message is classified as the pattern's intent.
+	Won bool
+}
+
+// Explain reports every pattern which matches a message, best ranked first, and which of them won.
+// Reply patterns beat hear patterns, then patterns are ranked by priority, how anchored they are,
+// specificity and finally the order they were registered in. A trailing `--seed <n>` is ignored, as
+// it is by Classify.
+func (c *Classifier) Explain(body string, toBot bool) []Match {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	body, _, _ = splitSeed(body)
+	cs := c.match(body, toBot)
+	win := winner(cs)
+	out := make([]Match, len(cs))
+	for i, cand := range cs {
+		out[i] = Match{
+			IntentID:    cand.id,
+			Pattern:     cand.re.String(),
+			Reply:       cand.reply,
+			Priority:    cand.priority,
+			Anchors:     cand.anchors,
+			Specificity: cand.specificity,
+			Order:       cand.order,
+			Multi:       cand.multi,
+			Won:         i == win,
+		}
+	}
+	return out
+}
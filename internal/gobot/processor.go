@@ -3,22 +3,105 @@ package gobot
 import (
 	"context"
 	"fmt"
+	"math/rand"
+	"sync"
+	"time"
 
 	"github.com/spy16/snowman"
 )
 
 // Processor is a type which implements the snowman.Processor interface. It has a registry of
 // actions and understands how to map an intent to an action, registered by a separate module.
+// Actions can be registered, replaced and unregistered while intents are being processed.
 type Processor struct {
-	actions map[string]snowman.ProcessorFunc
+	// amu guards actions.
+	amu        sync.RWMutex
+	actions    map[string]snowman.ProcessorFunc
+	middleware []Middleware
+	ui         snowman.UI
+
+	// rng generates the seed of each request's random source.
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// Middleware wraps the action registered for an intent, for example to decide whether it runs at
+// all. It's called on every request, after the intent's random source has been set up.
+type Middleware func(next snowman.ProcessorFunc) snowman.ProcessorFunc
+
+// Use adds middleware which wraps every action. Middleware added first is outermost, so runs first.
+func (pp *Processor) Use(mw ...Middleware) {
+	pp.middleware = append(pp.middleware, mw...)
 }
 
-// Process implements the Process method for a snowman.Processor interface.
+// Process implements the Process method for a snowman.Processor interface. Each action is run with
+// its own random source, available through Rand, which is seeded with the seed requested by the
+// user or otherwise the next seed from the processor. The seed is recorded on the reply. Actions can
+// post further messages with Emit when the processor has a UI.
+//
+// Any other intents fired by the same message, under AlsoKey, are processed afterwards in the same
+// way. Their replies are posted as soon as they're ready, so before the reply to intent.
 func (pp *Processor) Process(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
-	if action, ok := pp.actions[intent.ID]; ok {
-		return action(ctx, intent)
+	msg, err := pp.process(ctx, intent)
+	also, _ := intent.Ctx[AlsoKey].([]snowman.Intent)
+	for _, in := range also {
+		in.Msg = intent.Msg
+		reply, alsoErr := pp.process(ctx, in)
+		if alsoErr == nil && reply.Body != "" {
+			if pp.ui == nil {
+				alsoErr = fmt.Errorf("unable to reply to %q: no UI to post to", in.ID)
+			} else {
+				alsoErr = pp.ui.Say(ctx, intent.Msg.From, reply)
+			}
+		}
+		if err == nil {
+			err = alsoErr
+		}
 	}
-	return snowman.Msg{}, nil
+	return msg, err
+}
+
+// process runs the action registered for a single intent.
+func (pp *Processor) process(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	pp.amu.RLock()
+	action, ok := pp.actions[intent.ID]
+	pp.amu.RUnlock()
+	if !ok {
+		return snowman.Msg{}, nil
+	}
+	seed, ok := intent.Ctx[SeedKey].(int64)
+	if !ok {
+		seed = pp.nextSeed()
+	}
+	ctx = withEmitter(WithRand(ctx, seed), &emitter{ui: pp.ui, user: intent.Msg.From, seed: seed})
+	for i := len(pp.middleware) - 1; i >= 0; i-- {
+		action = pp.middleware[i](action)
+	}
+	msg, err := action(ctx, intent)
+	if msg.Attribs != nil {
+		msg.Attribs[SeedKey] = seed
+	}
+	return msg, err
+}
+
+// SetUI sets the UI messages emitted by actions are posted to, which should be the one the bot is
+// running with.
+func (pp *Processor) SetUI(ui snowman.UI) {
+	pp.ui = ui
+}
+
+// Seed resets the processor's random source so that the seeds given to every following request
+// are reproducible.
+func (pp *Processor) Seed(seed int64) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	pp.rng = rand.New(rand.NewSource(seed))
+}
+
+func (pp *Processor) nextSeed() int64 {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	return pp.rng.Int63()
 }
 
 // Register adds a given processor function to the actions registry for and instance of the
@@ -27,6 +110,8 @@ func (pp *Processor) Register(intentID string, fun snowman.ProcessorFunc) error
 	if pp == nil || pp.actions == nil {
 		return fmt.Errorf("unable to register")
 	}
+	pp.amu.Lock()
+	defer pp.amu.Unlock()
 	if _, found := pp.actions[intentID]; found {
 		return fmt.Errorf("action with name already exists")
 	}
@@ -34,9 +119,34 @@ func (pp *Processor) Register(intentID string, fun snowman.ProcessorFunc) error
 	return nil
 }
 
+// Replace swaps the processor function registered for an intent ID. Intents already being processed
+// finish with the old function.
+func (pp *Processor) Replace(intentID string, fun snowman.ProcessorFunc) error {
+	pp.amu.Lock()
+	defer pp.amu.Unlock()
+	if _, found := pp.actions[intentID]; !found {
+		return fmt.Errorf("no action registered for %q", intentID)
+	}
+	pp.actions[intentID] = fun
+	return nil
+}
+
+// Unregister removes the processor function registered for an intent ID, after which its intents are
+// ignored.
+func (pp *Processor) Unregister(intentID string) error {
+	pp.amu.Lock()
+	defer pp.amu.Unlock()
+	if _, found := pp.actions[intentID]; !found {
+		return fmt.Errorf("no action registered for %q", intentID)
+	}
+	delete(pp.actions, intentID)
+	return nil
+}
+
 // NewProcessor is a constructor which returns a pointer to an instance of Processor.
 func NewProcessor() *Processor {
 	pp := &Processor{}
 	pp.actions = make(map[string]snowman.ProcessorFunc)
+	pp.Seed(time.Now().UnixNano())
 	return pp
 }
@@ -0,0 +1,166 @@
+package gobot
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/spy16/snowman"
+)
+
+func TestProcessor(t *testing.T) {
+	pp := NewProcessor()
+	echo := func(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+		return snowman.Msg{Body: intent.ID}, nil
+	}
+	if err := pp.Register("echo", echo); err != nil {
+		t.Fatal(err)
+	}
+	if err := pp.Register("echo", echo); err == nil {
+		t.Errorf("expected registering a duplicate intent to fail")
+	}
+
+	got, err := pp.Process(context.Background(), snowman.Intent{ID: "echo"})
+	if err != nil || got.Body != "echo" {
+		t.Errorf("Process(echo) = %q, %v", got.Body, err)
+	}
+	got, err = pp.Process(context.Background(), snowman.Intent{ID: "missing"})
+	if err != nil || got.Body != "" {
+		t.Errorf("Process(missing) = %q, %v, want an empty message", got.Body, err)
+	}
+
+	upper := func(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+		return snowman.Msg{Body: "ECHO"}, nil
+	}
+	if err := pp.Replace("echo", upper); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := pp.Process(context.Background(), snowman.Intent{ID: "echo"}); got.Body != "ECHO" {
+		t.Errorf("Process(echo) = %q after replacing it, want ECHO", got.Body)
+	}
+	if err := pp.Unregister("echo"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := pp.Process(context.Background(), snowman.Intent{ID: "echo"}); got.Body != "" {
+		t.Errorf("Process(echo) = %q after unregistering it, want an empty message", got.Body)
+	}
+	if err := pp.Replace("echo", upper); err == nil {
+		t.Errorf("expected replacing an unregistered intent to fail")
+	}
+	if err := pp.Unregister("echo"); err == nil {
+		t.Errorf("expected unregistering an unknown intent to fail")
+	}
+
+	var nilProc *Processor
+	if err := nilProc.Register("echo", echo); err == nil {
+		t.Errorf("expected registering with a nil processor to fail")
+	}
+}
+
+func TestProcessorSeed(t *testing.T) {
+	roll := func(ctx context.Context, _ snowman.Intent) (snowman.Msg, error) {
+		seed, _ := Seed(ctx)
+		return snowman.Msg{Body: fmt.Sprint(Rand(ctx).Int63()), Attribs: map[string]interface{}{"seed": seed}}, nil
+	}
+	rolls := func(pp *Processor, ctx map[string]interface{}) []snowman.Msg {
+		var out []snowman.Msg
+		for i := 0; i < 3; i++ {
+			msg, err := pp.Process(context.Background(), snowman.Intent{ID: "roll", Ctx: ctx})
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, msg)
+		}
+		return out
+	}
+
+	a, b := NewProcessor(), NewProcessor()
+	for _, pp := range []*Processor{a, b} {
+		pp.Seed(7)
+		if err := pp.Register("roll", roll); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ra, rb := rolls(a, nil), rolls(b, nil)
+	for i := range ra {
+		if ra[i].Body != rb[i].Body {
+			t.Errorf("roll %v differs between processors with the same seed: %q != %q", i, ra[i].Body, rb[i].Body)
+		}
+		if ra[i].Attribs[SeedKey] != ra[i].Attribs["seed"] {
+			t.Errorf("reply records seed %v, action saw %v", ra[i].Attribs[SeedKey], ra[i].Attribs["seed"])
+		}
+	}
+	if ra[0].Body == ra[1].Body {
+		t.Errorf("expected each request to get a different seed")
+	}
+
+	// Replaying a recorded seed reproduces the outcome.
+	replay := rolls(b, map[string]interface{}{SeedKey: ra[1].Attribs[SeedKey]})
+	if replay[0].Body != ra[1].Body || replay[2].Body != ra[1].Body {
+		t.Errorf("replaying seed %v gave %q, want %q", ra[1].Attribs[SeedKey], replay[0].Body, ra[1].Body)
+	}
+}
+
+func TestProcessorUse(t *testing.T) {
+	pp := NewProcessor()
+	if err := pp.Register("echo", func(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+		return snowman.Msg{Body: intent.ID}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	wrap := func(name string) Middleware {
+		return func(next snowman.ProcessorFunc) snowman.ProcessorFunc {
+			return func(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
+				if intent.Ctx["block"] == name {
+					return snowman.Msg{Body: "blocked by " + name}, nil
+				}
+				msg, err := next(ctx, intent)
+				msg.Body = name + "(" + msg.Body + ")"
+				return msg, err
+			}
+		}
+	}
+	pp.Use(wrap("outer"), wrap("inner"))
+
+	tests := []struct {
+		block string
+		want  string
+	}{
+		{"", "outer(inner(echo))"},
+		{"inner", "outer(blocked by inner)"},
+		{"outer", "blocked by outer"},
+	}
+	for _, tt := range tests {
+		got, err := pp.Process(context.Background(), snowman.Intent{ID: "echo", Ctx: map[string]interface{}{"block": tt.block}})
+		if err != nil || got.Body != tt.want {
+			t.Errorf("Process(block=%q) = %q, %v, want %q", tt.block, got.Body, err, tt.want)
+		}
+	}
+}
+
+func TestProcessorAlso(t *testing.T) {
+	pp := NewProcessor()
+	ui := &recordingUI{}
+	pp.SetUI(ui)
+	echo := func(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+		return snowman.Msg{Body: intent.ID + " " + intent.Msg.Body, Attribs: map[string]interface{}{}}, nil
+	}
+	for _, id := range []string{"karma", "bob"} {
+		if err := pp.Register(id, echo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	intent := snowman.Intent{
+		ID:  "karma",
+		Msg: snowman.Msg{Body: "bob++"},
+		Ctx: map[string]interface{}{AlsoKey: []snowman.Intent{{ID: "bob", Ctx: map[string]interface{}{}}}},
+	}
+	got, err := pp.Process(context.Background(), intent)
+	if err != nil || got.Body != "karma bob++" {
+		t.Errorf("Process(karma) = %q, %v", got.Body, err)
+	}
+	if len(ui.said) != 1 || ui.said[0] != "bob bob++" {
+		t.Errorf("expected the other intent's reply to be posted, got %+v", ui.said)
+	}
+}
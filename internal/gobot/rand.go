@@ -0,0 +1,58 @@
+package gobot
+
+import (
+	"context"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// SeedKey is the intent context key holding a seed requested by the user with a trailing
+// `--seed <n>`, and the message attribute recording the seed a reply was generated with.
+const SeedKey = "rand_seed"
+
+// seedExp matches a request to reproduce a random outcome at the end of a message.
+var seedExp = regexp.MustCompile(`\s+--seed[= ](-?\d+)$`)
+
+// splitSeed removes a trailing `--seed <n>` from a message body, returning the seed if there was
+// one.
+func splitSeed(body string) (string, int64, bool) {
+	m := seedExp.FindStringSubmatchIndex(body)
+	if m == nil {
+		return body, 0, false
+	}
+	seed, err := strconv.ParseInt(body[m[2]:m[3]], 10, 64)
+	if err != nil {
+		return body, 0, false
+	}
+	return body[:m[0]], seed, true
+}
+
+type randKey struct{}
+
+type seededRand struct {
+	*rand.Rand
+	seed int64
+}
+
+// WithRand returns a copy of ctx carrying a random source created from seed.
+func WithRand(ctx context.Context, seed int64) context.Context {
+	return context.WithValue(ctx, randKey{}, seededRand{rand.New(rand.NewSource(seed)), seed})
+}
+
+// Rand returns the random source for the request being processed. Every outcome drawn from it can
+// be reproduced by processing the same request with the same seed. Outside of a request a source
+// seeded from the clock is returned.
+func Rand(ctx context.Context) *rand.Rand {
+	if r, ok := ctx.Value(randKey{}).(seededRand); ok {
+		return r.Rand
+	}
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
+
+// Seed returns the seed of the random source carried by ctx.
+func Seed(ctx context.Context) (int64, bool) {
+	r, ok := ctx.Value(randKey{}).(seededRand)
+	return r.seed, ok
+}
@@ -0,0 +1,155 @@
+package gobot
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// rateLimitMetrics counts requests which were allowed, and those which were limited by each kind of
+// bucket. It's published with expvar.
+var rateLimitMetrics = expvar.NewMap("rate_limits")
+
+// Limit is a token bucket which allows Burst requests at once, after which another is allowed every
+// Every. The zero value allows everything.
+type Limit struct {
+	Burst int
+	Every time.Duration
+}
+
+// UnmarshalYAML parses a limit written as {burst: 3, every: 1m}.
+func (l *Limit) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var raw struct {
+		Burst int    `yaml:"burst"`
+		Every string `yaml:"every"`
+	}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	every, err := time.ParseDuration(raw.Every)
+	if err != nil {
+		return fmt.Errorf("invalid rate limit interval %q: %w", raw.Every, err)
+	}
+	*l = Limit{Burst: raw.Burst, Every: every}
+	return nil
+}
+
+func (l Limit) unlimited() bool {
+	return l.Burst <= 0 || l.Every <= 0
+}
+
+// RateLimits configures how often requests are allowed.
+type RateLimits struct {
+	// User limits the requests of each user, across every intent.
+	User Limit `yaml:"user"`
+	// Channel limits the requests made in each channel, across every intent.
+	Channel Limit `yaml:"channel"`
+	// Intents limits how often each user can trigger the given intent IDs, acting as a cooldown.
+	Intents map[string]Limit `yaml:"intents"`
+}
+
+// sweepEvery is how often buckets which have filled back up are dropped. A full bucket is no
+// different from one which was never used, so this keeps memory bounded by recent activity.
+const sweepEvery = time.Minute
+
+// bucket holds the tokens left in a token bucket as of a point in time.
+type bucket struct {
+	tokens float64
+	at     time.Time
+	limit  Limit
+}
+
+// full reports whether the bucket has earned back every token it can hold by now.
+func (b *bucket) full(now time.Time) bool {
+	return b.tokens+float64(now.Sub(b.at))/float64(b.limit.Every) >= float64(b.limit.Burst)
+}
+
+// RateLimiter decides whether requests are allowed by keeping a token bucket for each user, each
+// channel and each user's use of an intent. It's safe for concurrent use.
+type RateLimiter struct {
+	limits RateLimits
+	now    func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+// NewRateLimiter returns a pointer to a RateLimiter which enforces the given limits.
+func NewRateLimiter(limits RateLimits) *RateLimiter {
+	return &RateLimiter{limits: limits, now: time.Now, buckets: map[string]*bucket{}}
+}
+
+// Allow reports whether a user can trigger an intent in a channel, taking a token from each bucket
+// if so. Otherwise nothing is taken, and it returns how long until the request would be allowed.
+func (rl *RateLimiter) Allow(user, channel, intentID string) (time.Duration, bool) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	checks := []struct {
+		kind, key string
+		limit     Limit
+	}{
+		{"user", "user/" + user, rl.limits.User},
+		{"channel", "channel/" + channel, rl.limits.Channel},
+		{"intent", "intent/" + intentID + "/" + user, rl.limits.Intents[intentID]},
+	}
+	now := rl.now()
+	if now.Sub(rl.swept) >= sweepEvery {
+		rl.sweep(now)
+	}
+	var wait time.Duration
+	var limited []string
+	var take []*bucket
+	for _, c := range checks {
+		if c.limit.unlimited() {
+			continue
+		}
+		b := rl.refill(c.key, c.limit, now)
+		if b.tokens >= 1 {
+			take = append(take, b)
+			continue
+		}
+		limited = append(limited, c.kind)
+		if w := time.Duration((1 - b.tokens) * float64(c.limit.Every)); w > wait {
+			wait = w
+		}
+	}
+	if len(limited) > 0 {
+		for _, kind := range limited {
+			rateLimitMetrics.Add("limited_"+kind, 1)
+		}
+		return wait, false
+	}
+	for _, b := range take {
+		b.tokens--
+	}
+	rateLimitMetrics.Add("allowed", 1)
+	return 0, true
+}
+
+// refill returns the bucket for key, topped up with the tokens earned since it was last used.
+func (rl *RateLimiter) refill(key string, l Limit, now time.Time) *bucket {
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.Burst), at: now, limit: l}
+		rl.buckets[key] = b
+	}
+	b.tokens += float64(now.Sub(b.at)) / float64(l.Every)
+	if b.tokens > float64(l.Burst) {
+		b.tokens = float64(l.Burst)
+	}
+	b.at = now
+	return b
+}
+
+// sweep drops the buckets which have filled back up.
+func (rl *RateLimiter) sweep(now time.Time) {
+	for key, b := range rl.buckets {
+		if b.full(now) {
+			delete(rl.buckets, key)
+		}
+	}
+	rl.swept = now
+}
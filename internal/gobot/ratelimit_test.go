@@ -0,0 +1,66 @@
+package gobot
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestRateLimiter(t *testing.T) {
+	var limits RateLimits
+	if err := yaml.UnmarshalStrict([]byte(`
+user: {burst: 3, every: 1m}
+intents:
+  emoji.spin: {burst: 1, every: 30s}
+`), &limits); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(0, 0)
+	rl := NewRateLimiter(limits)
+	rl.now = func() time.Time { return now }
+
+	allow := func(user, intent string) (time.Duration, bool) { return rl.Allow(user, "CGENERAL", intent) }
+
+	if _, ok := allow("UALICE", "emoji.spin"); !ok {
+		t.Fatalf("expected the first spin to be allowed")
+	}
+	if wait, ok := allow("UALICE", "emoji.spin"); ok || wait != 30*time.Second {
+		t.Errorf("second spin = %v, %v, want a 30s cooldown", wait, ok)
+	}
+	if _, ok := allow("UBOB", "emoji.spin"); !ok {
+		t.Errorf("expected cooldowns to be per user")
+	}
+	if _, ok := allow("UALICE", "roll"); !ok {
+		t.Errorf("expected other intents to be allowed")
+	}
+	if _, ok := allow("UALICE", "roll"); !ok {
+		t.Errorf("expected alice's third request to be allowed")
+	}
+	if wait, ok := allow("UALICE", "roll"); ok || wait != time.Minute {
+		t.Errorf("fourth request = %v, %v, want to wait a minute", wait, ok)
+	}
+
+	now = now.Add(30 * time.Second)
+	if wait, ok := allow("UALICE", "emoji.spin"); ok || wait != 30*time.Second {
+		t.Errorf("spin once the cooldown is over = %v, %v, want alice's overall limit to still apply", wait, ok)
+	}
+	now = now.Add(30 * time.Second)
+	if _, ok := allow("UALICE", "emoji.spin"); !ok {
+		t.Errorf("expected a spin to be allowed once a token has been earned")
+	}
+
+	// Buckets are dropped once they've filled back up, and not before.
+	now = now.Add(3 * time.Minute)
+	allow("UBOB", "roll")
+	if n := len(rl.buckets); n != 1 {
+		t.Errorf("got %v buckets after alice's filled up, want just bob's", n)
+	}
+	if _, ok := allow("UALICE", "emoji.spin"); !ok {
+		t.Errorf("expected a dropped bucket to start out full")
+	}
+
+	if err := yaml.UnmarshalStrict([]byte(`{burst: 1, every: soon}`), &Limit{}); err == nil {
+		t.Errorf("expected an invalid interval to be rejected")
+	}
+}
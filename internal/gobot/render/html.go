@@ -0,0 +1,141 @@
+package render
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/mattikus/gobot/internal/gobot/rich"
+)
+
+var (
+	// refExp matches an escaped link, or mention of a user or channel, in markdown, as in
+	// "<https://example.com|example>" or "<@U123>".
+	refExp = regexp.MustCompile(`&lt;(.+?)&gt;`)
+	// styles are the inline styles of markdown and the tags they become, applied in order.
+	styles = []struct {
+		exp *regexp.Regexp
+		tag string
+	}{
+		{regexp.MustCompile("```([^`]+)```"), "pre"},
+		{regexp.MustCompile("`([^`]+)`"), "code"},
+		{regexp.MustCompile(`(^|\W)\*([^*\n]+)\*`), "strong"},
+		{regexp.MustCompile(`(^|\W)_([^_\n]+)_`), "em"},
+		{regexp.MustCompile(`(^|\W)~([^~\n]+)~`), "del"},
+	}
+)
+
+// HTML describes rich content as an HTML fragment, with markdown converted to the equivalent tags.
+// Every element has a class naming the kind of block it came from, so the fragment can be styled.
+func HTML(blocks []rich.Block) string {
+	var out []string
+	for _, b := range blocks {
+		switch b := b.(type) {
+		case rich.Text:
+			out = append(out, `<div class="text">`+Markdown(b.Markdown)+"</div>")
+		case rich.Fields:
+			s := `<ul class="fields">`
+			for _, f := range b {
+				s += "<li>" + Markdown(f) + "</li>"
+			}
+			out = append(out, s+"</ul>")
+		case rich.Image:
+			s := fmt.Sprintf(`<figure class="image"><img src="%v" alt="%v">`, html.EscapeString(b.URL), html.EscapeString(b.Alt))
+			if b.Title != "" {
+				s += "<figcaption>" + html.EscapeString(b.Title) + "</figcaption>"
+			}
+			out = append(out, s+"</figure>")
+		case rich.Buttons:
+			s := `<div class="buttons">`
+			for _, btn := range b {
+				s += fmt.Sprintf(`<button data-action-id="%v" value="%v">%v</button>`,
+					html.EscapeString(btn.ActionID), html.EscapeString(btn.Value), html.EscapeString(btn.Label))
+			}
+			out = append(out, s+"</div>")
+		default:
+			out = append(out, fmt.Sprintf(`<div class="unsupported">%v</div>`, html.EscapeString(fmt.Sprintf("%T", b))))
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// Markdown converts markdown, written as Slack writes it, to HTML: bold, italic, strikethrough, code, quotes, links and
+// mentions. Mentions are shown with their label if they have one, or otherwise their ID, as looking
+// up names needs the workspace.
+func Markdown(text string) string {
+	var out, quoted []string
+	quoteRest := false
+	endQuote := func() {
+		if len(quoted) > 0 {
+			out = append(out, "<blockquote>"+strings.Join(quoted, "<br>")+"</blockquote>")
+			quoted = nil
+		}
+	}
+	for _, line := range strings.Split(text, "\n") {
+		quote := quoteRest
+		switch {
+		case strings.HasPrefix(line, ">>>"):
+			line, quote, quoteRest = line[3:], true, true
+		case strings.HasPrefix(line, ">"):
+			line, quote = line[1:], true
+		}
+		line = inline(html.EscapeString(line))
+		if quote {
+			quoted = append(quoted, line)
+			continue
+		}
+		endQuote()
+		out = append(out, line)
+	}
+	endQuote()
+	return strings.Join(out, "<br>")
+}
+
+// inline converts the styles, links and mentions within a line of escaped markdown. Styles aren't
+// applied within links, so that URLs are left alone.
+func inline(s string) string {
+	var out strings.Builder
+	last := 0
+	for _, m := range refExp.FindAllStringSubmatchIndex(s, -1) {
+		out.WriteString(style(s[last:m[0]]))
+		out.WriteString(ref(s[m[2]:m[3]]))
+		last = m[1]
+	}
+	out.WriteString(style(s[last:]))
+	return out.String()
+}
+
+// ref converts the inside of an escaped link or mention.
+func ref(s string) string {
+	target, label := s, ""
+	if i := strings.Index(s, "|"); i >= 0 {
+		target, label = s[:i], s[i+1:]
+	}
+	switch {
+	case strings.HasPrefix(target, "@") || strings.HasPrefix(target, "#"):
+		if label == "" {
+			label = target[1:]
+		}
+		return fmt.Sprintf(`<span class="mention">%v%v</span>`, target[:1], label)
+	case strings.HasPrefix(target, "http:") || strings.HasPrefix(target, "https:") || strings.HasPrefix(target, "mailto:"):
+		if label == "" {
+			label = target
+		}
+		return fmt.Sprintf(`<a href="%v">%v</a>`, target, style(label))
+	default:
+		return "&lt;" + s + "&gt;"
+	}
+}
+
+// style converts the inline styles of escaped markdown.
+func style(s string) string {
+	for _, st := range styles {
+		if st.tag == "pre" || st.tag == "code" {
+			s = st.exp.ReplaceAllString(s, "<"+st.tag+">$1</"+st.tag+">")
+			continue
+		}
+		s = st.exp.ReplaceAllString(s, "$1<"+st.tag+">$2</"+st.tag+">")
+	}
+	return s
+}
@@ -0,0 +1,57 @@
+// Package render converts rich content into plain text and HTML for UIs which can't display it
+// themselves, logs and tests.
+package render
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mattikus/gobot/internal/gobot/rich"
+)
+
+// linkExp matches a link in markdown, with or without a label.
+var linkExp = regexp.MustCompile(`<((?:https?|mailto):[^|>]+)(?:\|([^>]+))?>`)
+
+// Text describes rich content as plain text, a line or more per block. Markdown is kept as it's
+// written, apart from links which are shown with their URL. Buttons are shown with the action IDs
+// they're clicked with.
+func Text(blocks []rich.Block) string {
+	var lines []string
+	for _, b := range blocks {
+		switch b := b.(type) {
+		case rich.Text:
+			lines = append(lines, textOf(b.Markdown))
+		case rich.Fields:
+			for _, f := range b {
+				lines = append(lines, textOf(f))
+			}
+		case rich.Image:
+			line := fmt.Sprintf("[image: %v %v]", b.Alt, b.URL)
+			if b.Title != "" {
+				line = b.Title + " " + line
+			}
+			lines = append(lines, line)
+		case rich.Buttons:
+			var buttons []string
+			for _, btn := range b {
+				buttons = append(buttons, fmt.Sprintf("[%v](%v)", btn.Label, btn.ActionID))
+			}
+			lines = append(lines, strings.Join(buttons, " "))
+		default:
+			lines = append(lines, fmt.Sprintf("[%T]", b))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// textOf returns markdown with any links replaced by their label and URL.
+func textOf(markdown string) string {
+	return linkExp.ReplaceAllStringFunc(markdown, func(s string) string {
+		m := linkExp.FindStringSubmatch(s)
+		if m[2] == "" {
+			return m[1]
+		}
+		return m[2] + " (" + m[1] + ")"
+	})
+}
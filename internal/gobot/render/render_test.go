@@ -0,0 +1,52 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/mattikus/gobot/internal/gobot/rich"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name       string
+		blocks     []rich.Block
+		text, html string
+	}{
+		{
+			name:   "text",
+			blocks: []rich.Block{rich.Text{Markdown: "*Pick 2* <https://example.com/a_b|the _rules_> & <@U1>"}},
+			text:   "*Pick 2* the _rules_ (https://example.com/a_b) & <@U1>",
+			html:   `<div class="text"><strong>Pick 2</strong> <a href="https://example.com/a_b">the <em>rules</em></a> &amp; <span class="mention">@U1</span></div>`,
+		},
+		{
+			name:   "fields",
+			blocks: []rich.Block{rich.Fields{">>>*Name:*\nJo <Jo>", "`code` ~gone~ in <#C1|general>"}},
+			text:   ">>>*Name:*\nJo <Jo>\n`code` ~gone~ in <#C1|general>",
+			html:   `<ul class="fields"><li><blockquote><strong>Name:</strong><br>Jo &lt;Jo&gt;</blockquote></li><li><code>code</code> <del>gone</del> in <span class="mention">#general</span></li></ul>`,
+		},
+		{
+			name:   "image",
+			blocks: []rich.Block{rich.Image{URL: "https://example.com/p.jpg", Alt: "A <player>", Title: "Jo *"}},
+			text:   "Jo * [image: A <player> https://example.com/p.jpg]",
+			html:   `<figure class="image"><img src="https://example.com/p.jpg" alt="A &lt;player&gt;"><figcaption>Jo *</figcaption></figure>`,
+		},
+		{
+			name: "buttons",
+			blocks: []rich.Block{
+				rich.Text{Markdown: "> quoted\nnot"},
+				rich.Buttons{{ActionID: "cards.deal_again", Value: "3", Label: "Deal again"}, {ActionID: "stop", Label: "<Stop>"}},
+			},
+			text: "> quoted\nnot\n[Deal again](cards.deal_again) [<Stop>](stop)",
+			html: `<div class="text"><blockquote> quoted</blockquote><br>not</div>` + "\n" +
+				`<div class="buttons"><button data-action-id="cards.deal_again" value="3">Deal again</button><button data-action-id="stop" value="">&lt;Stop&gt;</button></div>`,
+		},
+	}
+	for _, tt := range tests {
+		if got := Text(tt.blocks); got != tt.text {
+			t.Errorf("%v: Text() = %q, want %q", tt.name, got, tt.text)
+		}
+		if got := HTML(tt.blocks); got != tt.html {
+			t.Errorf("%v: HTML() = %q, want %q", tt.name, got, tt.html)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Package rich describes the rich content of messages, such as formatted text, images and buttons,
+// independently of how any UI displays it. Modules build it, and each UI translates it into its own
+// format.
+package rich
+
+import (
+	"regexp"
+
+	"github.com/spy16/snowman"
+)
+
+// Key is the message attribute holding the rich content of a message, as a []Block. UIs which can't
+// display it fall back to the body of the message.
+const Key = "rich_content"
+
+// Block is a piece of rich content, shown below the blocks before it.
+type Block interface {
+	block()
+}
+
+// Text is a paragraph of text formatted with markdown. Markdown is written the way Slack writes it:
+// *bold*, _italic_, ~strikethrough~, `code`, lines starting with > or >>> quoted, <url|label> for
+// links and <@U123> or <#C123> for mentions. Mentions hold whatever ID the UI gives users and
+// channels, and may be followed by a name as in <@U123|alice>.
+type Text struct {
+	Markdown string
+}
+
+// Image is a picture, with alternative text for anyone who can't see it and an optional title.
+type Image struct {
+	URL   string
+	Alt   string
+	Title string
+}
+
+// Fields is a list of short pieces of markdown text, shown side by side where there's room.
+type Fields []string
+
+// Buttons is a row of buttons.
+type Buttons []Button
+
+// Button is a button which, when clicked, triggers the action registered for its action ID with
+// its value.
+type Button struct {
+	ActionID string
+	Value    string
+	Label    string
+}
+
+func (Text) block()    {}
+func (Image) block()   {}
+func (Fields) block()  {}
+func (Buttons) block() {}
+
+// Blocks returns the rich content of a message, if any.
+func Blocks(msg snowman.Msg) []Block {
+	blocks, _ := msg.Attribs[Key].([]Block)
+	return blocks
+}
+
+// mentionExp matches text which is nothing but a mention of a user or channel, with or without a
+// name.
+var mentionExp = regexp.MustCompile(`^<([@#])([^\s|>]+)(?:\|[^>]*)?>$`)
+
+// Mention returns the markdown mentioning the user with the given ID.
+func Mention(id string) string {
+	return "<@" + id + ">"
+}
+
+// ParseMention returns the ID of the user mentioned, if s is nothing but a mention of one.
+func ParseMention(s string) (string, bool) {
+	return parse(s, "@")
+}
+
+// ParseChannel returns the ID of the channel mentioned, if s is nothing but a mention of one.
+func ParseChannel(s string) (string, bool) {
+	return parse(s, "#")
+}
+
+func parse(s, kind string) (string, bool) {
+	m := mentionExp.FindStringSubmatch(s)
+	if m == nil || m[1] != kind {
+		return "", false
+	}
+	return m[2], true
+}
@@ -0,0 +1,31 @@
+package rich
+
+import "testing"
+
+func TestParseMention(t *testing.T) {
+	tests := []struct {
+		s, user, channel string
+	}{
+		{"<@U123>", "U123", ""},
+		{"<@U123|alice>", "U123", ""},
+		{"<@123456789012345678>", "123456789012345678", ""},
+		{"<@~alice>", "~alice", ""},
+		{"<#C123|general>", "", "C123"},
+		{"<##gobot>", "", "#gobot"},
+		{"<@U123> hi", "", ""},
+		{"@alice", "", ""},
+	}
+	for _, tt := range tests {
+		user, ok := ParseMention(tt.s)
+		if user != tt.user || ok != (tt.user != "") {
+			t.Errorf("ParseMention(%q) = %q, %v, want %q", tt.s, user, ok, tt.user)
+		}
+		channel, ok := ParseChannel(tt.s)
+		if channel != tt.channel || ok != (tt.channel != "") {
+			t.Errorf("ParseChannel(%q) = %q, %v, want %q", tt.s, channel, ok, tt.channel)
+		}
+	}
+	if m := Mention("U123"); m != "<@U123>" {
+		t.Errorf("Mention(U123) = %q", m)
+	}
+}
@@ -0,0 +1,42 @@
+package slack
+
+import (
+	"github.com/slack-go/slack"
+
+	"github.com/mattikus/gobot/internal/gobot/rich"
+)
+
+// blockKit translates rich content into the Block Kit blocks Slack displays.
+func blockKit(content []rich.Block) []slack.Block {
+	var blocks []slack.Block
+	for _, b := range content {
+		switch b := b.(type) {
+		case rich.Text:
+			blocks = append(blocks, slack.NewSectionBlock(mrkdwn(b.Markdown), nil, nil))
+		case rich.Fields:
+			var fields []*slack.TextBlockObject
+			for _, f := range b {
+				fields = append(fields, mrkdwn(f))
+			}
+			blocks = append(blocks, slack.NewSectionBlock(nil, fields, nil))
+		case rich.Image:
+			var title *slack.TextBlockObject
+			if b.Title != "" {
+				title = slack.NewTextBlockObject(slack.PlainTextType, b.Title, false, false)
+			}
+			blocks = append(blocks, slack.NewImageBlock(b.URL, b.Alt, "", title))
+		case rich.Buttons:
+			var buttons []slack.BlockElement
+			for _, btn := range b {
+				buttons = append(buttons, slack.NewButtonBlockElement(btn.ActionID, btn.Value,
+					slack.NewTextBlockObject(slack.PlainTextType, btn.Label, false, false)))
+			}
+			blocks = append(blocks, slack.NewActionBlock("", buttons...))
+		}
+	}
+	return blocks
+}
+
+func mrkdwn(text string) *slack.TextBlockObject {
+	return slack.NewTextBlockObject(slack.MarkdownType, text, false, false)
+}
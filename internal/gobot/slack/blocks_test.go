@@ -0,0 +1,28 @@
+package slack
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mattikus/gobot/internal/gobot/rich"
+)
+
+func TestBlockKit(t *testing.T) {
+	blocks := blockKit([]rich.Block{
+		rich.Image{URL: "https://example.com/p.jpg", Alt: "Jo"},
+		rich.Text{Markdown: "*Jo*"},
+		rich.Fields{"*Name:*\nJo", "*College:*\nNone"},
+		rich.Buttons{{ActionID: "again", Value: "1", Label: "Again"}},
+	})
+	got, err := json.Marshal(blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"type":"image","image_url":"https://example.com/p.jpg","alt_text":"Jo"},` +
+		`{"type":"section","text":{"type":"mrkdwn","text":"*Jo*"}},` +
+		`{"type":"section","fields":[{"type":"mrkdwn","text":"*Name:*\nJo"},{"type":"mrkdwn","text":"*College:*\nNone"}]},` +
+		`{"type":"actions","elements":[{"type":"button","text":{"type":"plain_text","text":"Again"},"action_id":"again","value":"1"}]}]`
+	if string(got) != want {
+		t.Errorf("blockKit() = %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,64 @@
+package slack
+
+import (
+	"context"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot/attr"
+)
+
+// handleInteraction turns every block action in an interaction, such as a button being clicked,
+// into a message for the bot. Other kinds of interaction are ignored.
+func (sl *Slack) handleInteraction(ctx context.Context, ic *slack.InteractionCallback, out chan<- snowman.Msg) {
+	if ic.Type != slack.InteractionTypeBlockActions {
+		sl.Debugf("ignoring interaction (type=%v)", ic.Type)
+		return
+	}
+
+	// The message the action was attached to stands in for the message being replied to, so that
+	// replies end up in the right place and can update it.
+	ev := &slackevents.MessageEvent{
+		Type:            "message",
+		User:            ic.User.ID,
+		Text:            ic.Message.Text,
+		Channel:         ic.Channel.ID,
+		TimeStamp:       ic.Message.Timestamp,
+		ThreadTimeStamp: ic.Message.ThreadTimestamp,
+	}
+	if ic.Container.MessageTs != "" {
+		ev.TimeStamp = ic.Container.MessageTs
+	}
+	for _, action := range ic.ActionCallback.BlockActions {
+		value := action.Value
+		if value == "" {
+			value = action.SelectedOption.Value
+		}
+		msg := snowman.Msg{
+			From: snowman.User{
+				ID:   ic.User.ID,
+				Name: ic.User.Name,
+			},
+			// The body is never classified, but messages without one are dropped.
+			Body: action.ActionID,
+			Attribs: map[string]interface{}{
+				"slack_msg":         ev,
+				"slack_interaction": ic,
+				attr.Action:         action.ActionID,
+				attr.ActionValue:    value,
+				attr.Channel:        ev.Channel,
+				attr.ID:             ev.TimeStamp,
+				attr.Thread:         ev.ThreadTimeStamp,
+				attr.DM:             ic.Channel.Name == "directmessage",
+				attr.ToBot:          true,
+			},
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case out <- msg:
+		}
+	}
+}
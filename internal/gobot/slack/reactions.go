@@ -0,0 +1,55 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/slack-go/slack/slackevents"
+	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot/attr"
+)
+
+// handleReaction turns an emoji reaction being added to, or removed from, a message into a message
+// for the bot. Reactions to anything other than messages are ignored.
+func (sl *Slack) handleReaction(ctx context.Context, ev slackevents.ReactionAddedEvent, removed bool, out chan<- snowman.Msg) {
+	if ev.Item.Type != "message" || ev.User == sl.self.UserID {
+		return
+	}
+	user, err := sl.client.GetUserInfo(ev.User)
+	if err != nil {
+		sl.Errorf("GetUserInfo(%q): %v", ev.User, err)
+		return
+	}
+
+	// The message reacted to stands in for the message being replied to, so that replies end up in
+	// the right place and modules know who wrote it.
+	item := &slackevents.MessageEvent{
+		Type:      "message",
+		User:      ev.ItemUser,
+		Channel:   ev.Item.Channel,
+		TimeStamp: ev.Item.Timestamp,
+	}
+	msg := snowman.Msg{
+		From: snowman.User{
+			ID:   user.ID,
+			Name: user.RealName,
+		},
+		// The body is never classified, but messages without one are dropped.
+		Body: fmt.Sprintf(":%v:", ev.Reaction),
+		Attribs: map[string]interface{}{
+			"slack_msg":          item,
+			"slack_user":         *user,
+			attr.Reaction:        ev.Reaction,
+			attr.ReactionRemoved: removed,
+			attr.Channel:         item.Channel,
+			attr.ID:              item.TimeStamp,
+			attr.Author:          item.User,
+			attr.ToBot:           false,
+		},
+	}
+	select {
+	case <-ctx.Done():
+	case out <- msg:
+	}
+}
@@ -8,30 +8,53 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"reflect"
 	"regexp"
 
+	"github.com/gorilla/websocket"
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
 	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot/attr"
+	"github.com/mattikus/gobot/internal/gobot/render"
+	"github.com/mattikus/gobot/internal/gobot/rich"
 )
 
 // regexTmpl is a string which defines a regex used by stripSelf to match messages addresssed
 // directly to us and then remove the bot's name.
 const regexTmpl = `(?i)^(?:(?P<self>%v|%v|%v)[:,]?)?\s*(?P<text>.*)$`
 
-func New(token, signingSecret, port string, logger logger) *Slack {
+// New returns a Slack UI which receives events by serving the Events API over HTTP on the given
+// address, such as ":8080".
+func New(token, signingSecret, addr string, logger logger) *Slack {
 	if logger == nil {
 		logger = snowman.NoOpLogger{}
 	}
 	return &Slack{
 		client:        slack.New(token),
 		signingSecret: signingSecret,
-		port:          port,
+		addr:          addr,
 		logger:        logger,
 	}
 }
 
+// NewSocketMode returns a Slack UI which receives events over an outbound Socket Mode connection
+// authenticated with the given app-level token, so the bot does not need to be publicly reachable.
+func NewSocketMode(token, appToken string, logger logger) *Slack {
+	if logger == nil {
+		logger = snowman.NoOpLogger{}
+	}
+	return &Slack{
+		client:   slack.New(token),
+		appToken: appToken,
+		apiURL:   slack.APIURL,
+		dialer:   websocket.DefaultDialer,
+		logger:   logger,
+	}
+}
+
 // Slack implements snowman UI using Slack RTM API.
 type Slack struct {
 	logger
@@ -41,12 +64,18 @@ type Slack struct {
 	self          *slack.Bot
 	SelfRegex     *regexp.Regexp
 	client        *slack.Client
-	port          string
+	addr          string
 	signingSecret string
+
+	// Socket Mode settings, only used when appToken is set.
+	appToken string
+	apiURL   string
+	dialer   *websocket.Dialer
 }
 
-// Listen starts an HTTP server and starts listening for slack events API. Message events
-// are pushed to the returned channel.
+// Listen connects to Slack and starts listening for events, either over Socket Mode when an
+// app-level token was provided or otherwise by starting an HTTP server for the events API. Message
+// events are pushed to the returned channel.
 func (sl *Slack) Listen(ctx context.Context) (<-chan snowman.Msg, error) {
 	sl.ctx, sl.cancel = context.WithCancel(context.Background())
 	defer sl.cancel()
@@ -74,57 +103,81 @@ func (sl *Slack) Listen(ctx context.Context) (<-chan snowman.Msg, error) {
 		return nil, err
 	}
 	sl.SelfRegex = re
-	go sl.listenForEvents(ctx, out)
+	if sl.appToken != "" {
+		go sl.listenSocketMode(ctx, out)
+	} else {
+		go sl.listenForEvents(ctx, out)
+	}
 
 	return out, nil
 }
 
+// Say posts a message to Slack. Messages for a single user are either posted ephemerally in the
+// channel, or sent in a DM opened with them.
 func (sl *Slack) Say(ctx context.Context, user snowman.User, msg snowman.Msg) error {
-	channel, ok := msg.Attribs["slack_channel"].(string)
+	if to, ok := msg.Attribs[attr.DMUser].(string); ok && to != "" {
+		ch, _, _, err := sl.client.OpenConversation(&slack.OpenConversationParameters{Users: []string{to}})
+		if err != nil {
+			return fmt.Errorf("unable to open DM with %q: %w", to, err)
+		}
+		msg.Attribs[attr.Channel] = ch.ID
+	}
+	channel, ok := msg.Attribs[attr.Channel].(string)
 	if !ok {
 		sl.Warnf("unable to get channel from context")
 		return nil
 	}
-	blocks := msg.Attribs["slack_blocks"].([]slack.Block)
+	content := rich.Blocks(msg)
+	if len(content) > 0 {
+		sl.Debugf("posting rich content to %v: %q", channel, render.Text(content))
+	}
+	blocks := blockKit(content)
+	if url, ok := msg.Attribs[attr.Response].(string); ok && url != "" {
+		return sl.respond(ctx, url, msg, blocks)
+	}
 	opts := []slack.MsgOption{
 		slack.MsgOptionAsUser(true),
 		slack.MsgOptionText(msg.Body, false),
 		slack.MsgOptionBlocks(blocks...),
 	}
+	if ts, ok := msg.Attribs[attr.Thread].(string); ok && ts != "" {
+		opts = append(opts, slack.MsgOptionTS(ts))
+		if broadcast, _ := msg.Attribs[attr.Broadcast].(bool); broadcast {
+			opts = append(opts, slack.MsgOptionBroadcast())
+		}
+	}
+	if ts, ok := msg.Attribs[attr.Update].(string); ok && ts != "" {
+		_, _, _, err := sl.client.UpdateMessage(channel, ts, opts...)
+		return err
+	}
+	if to, ok := msg.Attribs[attr.Ephemeral].(string); ok && to != "" {
+		_, err := sl.client.PostEphemeral(channel, to, opts...)
+		return err
+	}
 	_, _, err := sl.client.PostMessage(channel, opts...)
 	return err
 }
 
 func (sl *Slack) listenForEvents(ctx context.Context, out chan<- snowman.Msg) {
 	defer close(out)
+	sl.Infof("listening for HTTP slack events on %v", sl.addr)
+	if err := http.ListenAndServe(sl.addr, sl.handler(ctx, out)); err != nil {
+		sl.Errorf("unable to serve slack events: %v", err)
+	}
+}
 
-	http.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
+// handler returns the HTTP handler for every endpoint Slack sends requests to.
+func (sl *Slack) handler(ctx context.Context, out chan<- snowman.Msg) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
 		sl.Infof("health check")
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
 
-	http.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
-		body, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			sl.Errorf("unable to read event body: ", err)
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-		sv, err := slack.NewSecretsVerifier(r.Header, sl.signingSecret)
-		if err != nil {
-			sl.Errorf("unable to craft secrets verifier: ", err)
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-		if _, err := sv.Write(body); err != nil {
-			sl.Errorf("unable to parse secrets: ", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		if err := sv.Ensure(); err != nil {
-			sl.Errorf("unable to verify secrets: ", err)
-			w.WriteHeader(http.StatusUnauthorized)
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		body, ok := sl.verifiedBody(w, r)
+		if !ok {
 			return
 		}
 		eventsAPIEvent, err := slackevents.ParseEvent(json.RawMessage(body), slackevents.OptionNoVerifyToken())
@@ -146,22 +199,102 @@ func (sl *Slack) listenForEvents(ctx context.Context, out chan<- snowman.Msg) {
 			w.Header().Set("Content-Type", "text")
 			w.Write([]byte(r.Challenge))
 		case slackevents.CallbackEvent:
-			innerEvent := eventsAPIEvent.InnerEvent
-			sl.Debugf("event: %s [data=%#v]", innerEvent.Type, innerEvent.Data)
-			switch ev := innerEvent.Data.(type) {
-			case *slackevents.MessageEvent:
-				w.WriteHeader(http.StatusOK)
-				sl.handleMessage(ctx, ev, out)
-				return
-			default:
-				sl.Debugf("ignoring unknown event (type=%v)", reflect.TypeOf(ev))
-				w.WriteHeader(http.StatusOK)
-				return
-			}
+			w.WriteHeader(http.StatusOK)
+			sl.handleCallback(ctx, eventsAPIEvent, out)
 		}
 	})
-	sl.Infof("listening for HTTP slack events on port %v", sl.port)
-	http.ListenAndServe(":"+sl.port, nil)
+
+	mux.HandleFunc("/interactions", func(w http.ResponseWriter, r *http.Request) {
+		body, ok := sl.verifiedBody(w, r)
+		if !ok {
+			return
+		}
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			sl.Errorf("unable to parse interaction body: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var ic slack.InteractionCallback
+		if err := json.Unmarshal([]byte(form.Get("payload")), &ic); err != nil {
+			sl.Errorf("unable to parse interaction payload: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		sl.handleInteraction(ctx, &ic, out)
+	})
+
+	mux.HandleFunc("/commands", func(w http.ResponseWriter, r *http.Request) {
+		body, ok := sl.verifiedBody(w, r)
+		if !ok {
+			return
+		}
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			sl.Errorf("unable to parse slash command: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// Replies are sent to the response_url once processed, so there's nothing to respond with.
+		w.WriteHeader(http.StatusOK)
+		sl.handleSlashCommand(ctx, &slack.SlashCommand{
+			ChannelID:   form.Get("channel_id"),
+			ChannelName: form.Get("channel_name"),
+			UserID:      form.Get("user_id"),
+			UserName:    form.Get("user_name"),
+			Command:     form.Get("command"),
+			Text:        form.Get("text"),
+			ResponseURL: form.Get("response_url"),
+			TriggerID:   form.Get("trigger_id"),
+		}, out)
+	})
+	return mux
+}
+
+// verifiedBody reads the body of a request from Slack and checks it was signed with our signing
+// secret. If not, an error status is written and false returned.
+func (sl *Slack) verifiedBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		sl.Errorf("unable to read event body: ", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+	sv, err := slack.NewSecretsVerifier(r.Header, sl.signingSecret)
+	if err != nil {
+		sl.Errorf("unable to craft secrets verifier: ", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+	if _, err := sv.Write(body); err != nil {
+		sl.Errorf("unable to parse secrets: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	if err := sv.Ensure(); err != nil {
+		sl.Errorf("unable to verify secrets: ", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil, false
+	}
+	return body, true
+}
+
+// handleCallback dispatches the inner event of an events API callback, regardless of which
+// transport it was received over.
+func (sl *Slack) handleCallback(ctx context.Context, event slackevents.EventsAPIEvent, out chan<- snowman.Msg) {
+	innerEvent := event.InnerEvent
+	sl.Debugf("event: %s [data=%#v]", innerEvent.Type, innerEvent.Data)
+	switch ev := innerEvent.Data.(type) {
+	case *slackevents.MessageEvent:
+		sl.handleMessage(ctx, ev, out)
+	case *slackevents.ReactionAddedEvent:
+		sl.handleReaction(ctx, *ev, false, out)
+	case *slackevents.ReactionRemovedEvent:
+		sl.handleReaction(ctx, slackevents.ReactionAddedEvent(*ev), true, out)
+	default:
+		sl.Debugf("ignoring unknown event (type=%v)", reflect.TypeOf(ev))
+	}
 }
 
 func (sl *Slack) handleMessage(ctx context.Context, ev *slackevents.MessageEvent, out chan<- snowman.Msg) {
@@ -188,7 +321,11 @@ func (sl *Slack) handleMessage(ctx context.Context, ev *slackevents.MessageEvent
 		Attribs: map[string]interface{}{
 			"slack_msg":  ev,
 			"slack_user": *user,
-			"to_bot":     ev.ChannelType == "im" || tagged,
+			attr.Channel: ev.Channel,
+			attr.ID:      ev.TimeStamp,
+			attr.Thread:  ev.ThreadTimeStamp,
+			attr.DM:      ev.ChannelType == "im",
+			attr.ToBot:   ev.ChannelType == "im" || tagged,
 		},
 	}
 
@@ -0,0 +1,146 @@
+package slack
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot/attr"
+)
+
+// signedRequest builds a request to the given path signed with secret in the way Slack would.
+func signedRequest(t *testing.T, secret, path, body string) *http.Request {
+	t.Helper()
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%v:%v", ts, body)
+
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("X-Slack-Request-Timestamp", ts)
+	r.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return r
+}
+
+func TestMetricsNotServed(t *testing.T) {
+	sl := New("xoxb-test", "secret", "0", nil)
+	h := sl.handler(context.Background(), make(chan snowman.Msg))
+
+	// Metrics are served on their own address, never to whoever can reach Slack's endpoints.
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("metrics got status %v, want %v", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestInteractions(t *testing.T) {
+	sl := New("xoxb-test", "secret", "0", nil)
+	out := make(chan snowman.Msg, 1)
+	h := sl.handler(context.Background(), out)
+
+	payload := `{
+		"type": "block_actions",
+		"user": {"id": "UALICE", "name": "alice"},
+		"channel": {"id": "CGENERAL"},
+		"container": {"type": "message", "message_ts": "1.000002", "channel_id": "CGENERAL"},
+		"message": {"ts": "1.000002", "thread_ts": "1.000001", "text": "a card"},
+		"actions": [{"type": "button", "block_id": "b1", "action_id": "cards.deal_again", "value": "3"}]
+	}`
+	body := url.Values{"payload": {payload}}.Encode()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, signedRequest(t, "wrong", "/interactions", body))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unsigned interaction got status %v, want %v", w.Code, http.StatusUnauthorized)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, signedRequest(t, "secret", "/interactions", body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("interaction got status %v, want %v", w.Code, http.StatusOK)
+	}
+	select {
+	case msg := <-out:
+		if msg.Body != "cards.deal_again" || msg.From.ID != "UALICE" {
+			t.Errorf("got message %+v, want cards.deal_again from UALICE", msg)
+		}
+		if got := msg.Attribs[attr.ActionValue]; got != "3" {
+			t.Errorf("got action value %q, want %q", got, "3")
+		}
+		if got := msg.Attribs[attr.Thread]; got != "1.000001" {
+			t.Errorf("got thread %q, want %q", got, "1.000001")
+		}
+	default:
+		t.Fatal("expected the interaction to be delivered as a message")
+	}
+}
+
+func TestSlashCommands(t *testing.T) {
+	responses := make(chan slashResponse, 1)
+	responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp slashResponse
+		if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+			t.Errorf("unable to decode slash command response: %v", err)
+		}
+		responses <- resp
+	}))
+	defer responder.Close()
+
+	sl := New("xoxb-test", "secret", "0", nil)
+	sl.self = &slack.Bot{ID: "BBOT", UserID: "UBOT", Name: "gobot"}
+	out := make(chan snowman.Msg, 1)
+	h := sl.handler(context.Background(), out)
+
+	tests := []struct {
+		command, text, want string
+	}{
+		{"/gobot", "card 3", "card 3"},
+		{"/baseball", "", "baseball"},
+		{"/roll", "2d6", "roll 2d6"},
+	}
+	for _, tt := range tests {
+		body := url.Values{
+			"command":      {tt.command},
+			"text":         {tt.text},
+			"channel_id":   {"CGENERAL"},
+			"user_id":      {"UALICE"},
+			"user_name":    {"alice"},
+			"response_url": {responder.URL},
+		}.Encode()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, signedRequest(t, "secret", "/commands", body))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%v %v got status %v, want %v", tt.command, tt.text, w.Code, http.StatusOK)
+		}
+		msg := <-out
+		if msg.Body != tt.want || msg.Attribs[attr.ToBot] != true {
+			t.Errorf("%v %v was handled as %q, want %q addressed to the bot", tt.command, tt.text, msg.Body, tt.want)
+		}
+	}
+
+	reply := snowman.Msg{Body: "only for you", Attribs: map[string]interface{}{
+		attr.Channel:   "CGENERAL",
+		attr.Response:  responder.URL,
+		attr.Ephemeral: "UALICE",
+	}}
+	if err := sl.Say(context.Background(), snowman.User{}, reply); err != nil {
+		t.Fatalf("Say: %v", err)
+	}
+	if resp := <-responses; resp.Text != "only for you" || resp.ResponseType != ResponseEphemeral {
+		t.Errorf("responded with %+v, want an ephemeral %q", resp, "only for you")
+	}
+}
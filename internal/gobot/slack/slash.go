@@ -0,0 +1,101 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot/attr"
+)
+
+// Response types for replies to slash commands.
+const (
+	ResponseEphemeral = "ephemeral"
+	ResponseInChannel = "in_channel"
+)
+
+// slashResponse is the body posted to a slash command's response_url.
+type slashResponse struct {
+	ResponseType string        `json:"response_type"`
+	Text         string        `json:"text"`
+	Blocks       []slack.Block `json:"blocks,omitempty"`
+}
+
+// slashText returns the text a slash command is handled as. The command named after the bot takes
+// the rest of the message as a command, as in `/gobot card 3`, while any other command is treated
+// as the start of the message, so `/baseball` is the same as saying "baseball" to the bot.
+func (sl *Slack) slashText(cmd *slack.SlashCommand) string {
+	name := strings.TrimPrefix(cmd.Command, "/")
+	if sl.self != nil && strings.EqualFold(name, sl.self.Name) {
+		return strings.TrimSpace(cmd.Text)
+	}
+	return strings.TrimSpace(name + " " + cmd.Text)
+}
+
+// handleSlashCommand turns a slash command into a message addressed to the bot, so it's classified
+// and processed like any other. Replies are sent to the command's response_url.
+func (sl *Slack) handleSlashCommand(ctx context.Context, cmd *slack.SlashCommand, out chan<- snowman.Msg) {
+	ev := &slackevents.MessageEvent{
+		Type:    "message",
+		User:    cmd.UserID,
+		Text:    sl.slashText(cmd),
+		Channel: cmd.ChannelID,
+	}
+	if cmd.ChannelName == "directmessage" {
+		ev.ChannelType = "im"
+	}
+	msg := snowman.Msg{
+		From: snowman.User{
+			ID:   cmd.UserID,
+			Name: cmd.UserName,
+		},
+		Body: ev.Text,
+		Attribs: map[string]interface{}{
+			"slack_msg":     ev,
+			"slack_command": cmd,
+			attr.Channel:    ev.Channel,
+			attr.DM:         ev.ChannelType == "im",
+			attr.Response:   cmd.ResponseURL,
+			attr.ToBot:      true,
+		},
+	}
+	select {
+	case <-ctx.Done():
+	case out <- msg:
+	}
+}
+
+// respond replies to a slash command through its response_url. Replies are only visible to the user
+// who ran the command if they were marked as ephemeral.
+func (sl *Slack) respond(ctx context.Context, url string, msg snowman.Msg, blocks []slack.Block) error {
+	responseType := ResponseInChannel
+	if to, _ := msg.Attribs[attr.Ephemeral].(string); to != "" {
+		responseType = ResponseEphemeral
+	}
+	body, err := json.Marshal(slashResponse{ResponseType: responseType, Text: msg.Body, Blocks: blocks})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to respond to slash command: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to respond to slash command: %v", resp.Status)
+	}
+	return nil
+}
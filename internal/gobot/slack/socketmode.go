@@ -0,0 +1,164 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/spy16/snowman"
+)
+
+// reconnectDelay is how long to wait before opening a new Socket Mode connection after the
+// previous one failed.
+const reconnectDelay = 5 * time.Second
+
+// Envelope types sent by Slack over a Socket Mode connection.
+const (
+	envelopeHello       = "hello"
+	envelopeDisconnect  = "disconnect"
+	envelopeEventsAPI   = "events_api"
+	envelopeInteractive = "interactive"
+	envelopeSlash       = "slash_commands"
+)
+
+// envelope is the wrapper Slack sends around every Socket Mode message.
+type envelope struct {
+	EnvelopeID string          `json:"envelope_id"`
+	Type       string          `json:"type"`
+	Reason     string          `json:"reason"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// ack acknowledges receipt of an envelope. Slack redelivers any envelope which isn't acknowledged.
+type ack struct {
+	EnvelopeID string `json:"envelope_id"`
+}
+
+// connectionsOpenResponse is the response of the apps.connections.open API method.
+type connectionsOpenResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+	URL   string `json:"url"`
+}
+
+// listenSocketMode keeps a Socket Mode connection open until ctx is cancelled, reconnecting
+// whenever Slack asks us to or the connection drops.
+func (sl *Slack) listenSocketMode(ctx context.Context, out chan<- snowman.Msg) {
+	defer close(out)
+
+	for {
+		err := sl.runSocketMode(ctx, out)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			continue
+		}
+		sl.Errorf("socket mode connection failed: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+// runSocketMode opens a single Socket Mode connection and processes envelopes from it until it is
+// closed. It returns nil when Slack requested a reconnect.
+func (sl *Slack) runSocketMode(ctx context.Context, out chan<- snowman.Msg) error {
+	url, err := sl.openConnection(ctx)
+	if err != nil {
+		return err
+	}
+	conn, _, err := sl.dialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return fmt.Errorf("unable to dial socket mode URL: %w", err)
+	}
+	defer conn.Close()
+
+	// Reads block forever, so unblock them by closing the connection once we're cancelled.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	sl.Infof("listening for slack events over socket mode")
+	for {
+		var env envelope
+		if err := conn.ReadJSON(&env); err != nil {
+			return fmt.Errorf("unable to read socket mode envelope: %w", err)
+		}
+		if env.EnvelopeID != "" {
+			if err := conn.WriteJSON(ack{EnvelopeID: env.EnvelopeID}); err != nil {
+				return fmt.Errorf("unable to acknowledge envelope %q: %w", env.EnvelopeID, err)
+			}
+		}
+
+		switch env.Type {
+		case envelopeHello:
+			sl.Debugf("socket mode connection established")
+		case envelopeDisconnect:
+			sl.Infof("socket mode disconnect requested (reason=%v)", env.Reason)
+			return nil
+		case envelopeEventsAPI:
+			event, err := slackevents.ParseEvent(env.Payload, slackevents.OptionNoVerifyToken())
+			if err != nil {
+				sl.Errorf("unable to parse EventsAPI JSON message: %v", err)
+				continue
+			}
+			if event.Type == slackevents.CallbackEvent {
+				sl.handleCallback(ctx, event, out)
+			}
+		case envelopeInteractive:
+			var ic slack.InteractionCallback
+			if err := json.Unmarshal(env.Payload, &ic); err != nil {
+				sl.Errorf("unable to parse interaction payload: %v", err)
+				continue
+			}
+			sl.handleInteraction(ctx, &ic, out)
+		case envelopeSlash:
+			var cmd slack.SlashCommand
+			if err := json.Unmarshal(env.Payload, &cmd); err != nil {
+				sl.Errorf("unable to parse slash command payload: %v", err)
+				continue
+			}
+			sl.handleSlashCommand(ctx, &cmd, out)
+		default:
+			sl.Debugf("ignoring unknown socket mode envelope (type=%v)", env.Type)
+		}
+	}
+}
+
+// openConnection asks Slack for a new Socket Mode websocket URL using the app-level token.
+func (sl *Slack) openConnection(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sl.apiURL+"apps.connections.open", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+sl.appToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to open socket mode connection: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var r connectionsOpenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return "", fmt.Errorf("unable to decode apps.connections.open response: %w", err)
+	}
+	if !r.OK {
+		return "", fmt.Errorf("apps.connections.open failed: %v", r.Error)
+	}
+	return r.URL, nil
+}
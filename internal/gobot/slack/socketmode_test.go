@@ -0,0 +1,282 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/slack-go/slack"
+	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot/attr"
+)
+
+// fakeSlack serves just enough of the Slack Web API for a bot to connect and post messages, and a
+// Socket Mode websocket which sends each of the given envelopes in turn. Connections opened after a
+// reconnect are sent the envelopes in reconnects instead, or nothing once they run out.
+type fakeSlack struct {
+	*httptest.Server
+	envelopes  []interface{}
+	reconnects [][]interface{}
+	acks       chan string
+	posts      chan post
+
+	mu    sync.Mutex
+	conns int
+}
+
+// post is a request to one of the Web API methods which posts a message.
+type post struct {
+	method string
+	form   url.Values
+}
+
+func newFakeSlack(t *testing.T, envelopes ...interface{}) *fakeSlack {
+	f := &fakeSlack{envelopes: envelopes, acks: make(chan string, len(envelopes)), posts: make(chan post, 16)}
+	mux := http.NewServeMux()
+	reply := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(body))
+		}
+	}
+	mux.HandleFunc("/auth.test", reply(`{"ok":true,"user_id":"UBOT","bot_id":"BBOT"}`))
+	mux.HandleFunc("/bots.info", reply(`{"ok":true,"bot":{"id":"BBOT","user_id":"UBOT","name":"gobot"}}`))
+	mux.HandleFunc("/users.info", reply(`{"ok":true,"user":{"id":"UALICE","name":"alice","real_name":"Alice"}}`))
+	mux.HandleFunc("/conversations.open", reply(`{"ok":true,"channel":{"id":"DALICE"}}`))
+	for _, method := range []string{"chat.postMessage", "chat.postEphemeral"} {
+		method := method
+		mux.HandleFunc("/"+method, func(w http.ResponseWriter, r *http.Request) {
+			r.ParseForm()
+			f.posts <- post{method: method, form: r.PostForm}
+			reply(`{"ok":true,"channel":"`+r.PostForm.Get("channel")+`","ts":"1.000002"}`)(w, r)
+		})
+	}
+	mux.HandleFunc("/apps.connections.open", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer xapp-test" {
+			t.Errorf("apps.connections.open called with Authorization %q", got)
+		}
+		json.NewEncoder(w).Encode(connectionsOpenResponse{OK: true, URL: "ws" + strings.TrimPrefix(f.URL, "http") + "/ws"})
+	})
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("unable to upgrade websocket: %v", err)
+			return
+		}
+		defer conn.Close()
+		f.mu.Lock()
+		envelopes := f.envelopes
+		if f.conns > 0 {
+			envelopes = nil
+			if f.conns <= len(f.reconnects) {
+				envelopes = f.reconnects[f.conns-1]
+			}
+		}
+		f.conns++
+		f.mu.Unlock()
+		for _, env := range envelopes {
+			if err := conn.WriteJSON(env); err != nil {
+				return
+			}
+		}
+		for {
+			var a ack
+			if err := conn.ReadJSON(&a); err != nil {
+				return
+			}
+			f.acks <- a.EnvelopeID
+		}
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func TestSocketMode(t *testing.T) {
+	event := map[string]interface{}{
+		"type": "event_callback",
+		"event": map[string]interface{}{
+			"type":         "message",
+			"channel":      "CGENERAL",
+			"channel_type": "channel",
+			"user":         "UALICE",
+			"text":         "<@UBOT> card me",
+			"ts":           "1.000001",
+		},
+	}
+	f := newFakeSlack(t,
+		map[string]interface{}{"type": "hello"},
+		map[string]interface{}{"type": "events_api", "envelope_id": "env-1", "payload": event},
+	)
+
+	sl := NewSocketMode("xoxb-test", "xapp-test", nil)
+	sl.client = slack.New("xoxb-test", slack.OptionAPIURL(f.URL+"/"))
+	sl.apiURL = f.URL + "/"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgs, err := sl.Listen(ctx)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+
+	select {
+	case id := <-f.acks:
+		if id != "env-1" {
+			t.Errorf("acknowledged envelope %q, want env-1", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for envelope to be acknowledged")
+	}
+
+	select {
+	case msg := <-msgs:
+		if msg.Body != "card me" || msg.From.ID != "UALICE" || msg.Attribs[attr.ToBot] != true {
+			t.Errorf("got message %+v, want %q from UALICE addressed to the bot", msg, "card me")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+
+	cancel()
+	select {
+	case _, ok := <-msgs:
+		if ok {
+			t.Errorf("expected no more messages")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for listener to stop")
+	}
+}
+
+func TestSocketModeReconnect(t *testing.T) {
+	f := newFakeSlack(t,
+		map[string]interface{}{"type": "hello"},
+		map[string]interface{}{"type": "disconnect", "reason": "refresh_requested"},
+	)
+	f.reconnects = [][]interface{}{{
+		map[string]interface{}{"type": "hello"},
+		map[string]interface{}{"type": "events_api", "envelope_id": "env-2", "payload": map[string]interface{}{
+			"type": "event_callback",
+			"event": map[string]interface{}{
+				"type":         "message",
+				"channel":      "DALICE",
+				"channel_type": "im",
+				"user":         "UALICE",
+				"text":         "baseball me",
+				"ts":           "1.000002",
+			},
+		}},
+	}}
+
+	sl := NewSocketMode("xoxb-test", "xapp-test", nil)
+	sl.client = slack.New("xoxb-test", slack.OptionAPIURL(f.URL+"/"))
+	sl.apiURL = f.URL + "/"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgs, err := sl.Listen(ctx)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+
+	// Slack asking for a reconnect opens a new connection straight away, without waiting for
+	// reconnectDelay, and events carry on arriving over it.
+	select {
+	case msg := <-msgs:
+		if msg.Body != "baseball me" || msg.Attribs[attr.ToBot] != true {
+			t.Errorf("got message %+v, want %q in a DM", msg, "baseball me")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for message after reconnecting")
+	}
+	if id := <-f.acks; id != "env-2" {
+		t.Errorf("acknowledged envelope %q, want env-2", id)
+	}
+}
+
+func TestSayPrivately(t *testing.T) {
+	f := newFakeSlack(t)
+	sl := New("xoxb-test", "secret", "0", nil)
+	sl.client = slack.New("xoxb-test", slack.OptionAPIURL(f.URL+"/"))
+
+	tests := []struct {
+		name    string
+		attribs map[string]interface{}
+		method  string
+		channel string
+		user    string
+	}{
+		{"public", map[string]interface{}{attr.Channel: "CGENERAL"}, "chat.postMessage", "CGENERAL", ""},
+		{"ephemeral", map[string]interface{}{attr.Channel: "CGENERAL", attr.Ephemeral: "UALICE"}, "chat.postEphemeral", "CGENERAL", "UALICE"},
+		{"dm", map[string]interface{}{attr.DMUser: "UALICE"}, "chat.postMessage", "DALICE", ""},
+	}
+	for _, tt := range tests {
+		if err := sl.Say(context.Background(), snowman.User{}, snowman.Msg{Body: "hi", Attribs: tt.attribs}); err != nil {
+			t.Fatalf("%v: Say: %v", tt.name, err)
+		}
+		p := <-f.posts
+		if p.method != tt.method || p.form.Get("channel") != tt.channel || p.form.Get("user") != tt.user {
+			t.Errorf("%v: called %v in %q for %q, want %v in %q for %q", tt.name,
+				p.method, p.form.Get("channel"), p.form.Get("user"), tt.method, tt.channel, tt.user)
+		}
+	}
+}
+
+func TestReactions(t *testing.T) {
+	reaction := func(typ, user, name string) map[string]interface{} {
+		return map[string]interface{}{
+			"type": "event_callback",
+			"event": map[string]interface{}{
+				"type":      typ,
+				"user":      user,
+				"reaction":  name,
+				"item_user": "UBOB",
+				"item":      map[string]interface{}{"type": "message", "channel": "CGENERAL", "ts": "1.000001"},
+			},
+		}
+	}
+	f := newFakeSlack(t,
+		map[string]interface{}{"type": "events_api", "payload": reaction("reaction_added", "UBOT", "card")},
+		map[string]interface{}{"type": "events_api", "payload": reaction("reaction_added", "UALICE", "card")},
+		map[string]interface{}{"type": "events_api", "payload": reaction("reaction_removed", "UALICE", "fire")},
+	)
+	sl := NewSocketMode("xoxb-test", "xapp-test", nil)
+	sl.client = slack.New("xoxb-test", slack.OptionAPIURL(f.URL+"/"))
+	sl.apiURL = f.URL + "/"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgs, err := sl.Listen(ctx)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+
+	// The bot's own reaction is ignored, so the first message is alice's.
+	for _, want := range []struct {
+		name    string
+		removed bool
+	}{{"card", false}, {"fire", true}} {
+		select {
+		case msg := <-msgs:
+			if msg.Attribs[attr.Reaction] != want.name || msg.Attribs[attr.ReactionRemoved] != want.removed {
+				t.Errorf("got reaction %v (removed=%v), want %v (removed=%v)",
+					msg.Attribs[attr.Reaction], msg.Attribs[attr.ReactionRemoved], want.name, want.removed)
+			}
+			if msg.From.ID != "UALICE" || msg.Attribs[attr.Author] != "UBOB" || msg.Attribs[attr.ID] != "1.000001" {
+				t.Errorf("got reaction from %v to %v's message %v, want from UALICE to UBOB's message 1.000001",
+					msg.From.ID, msg.Attribs[attr.Author], msg.Attribs[attr.ID])
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %v reaction", want.name)
+		}
+	}
+}
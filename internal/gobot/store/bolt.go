@@ -0,0 +1,105 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Bolt is a Store backed by a BoltDB file, with one bucket per namespace.
+type Bolt struct {
+	db *bolt.DB
+}
+
+// OpenBolt opens, or creates, the BoltDB file at path.
+func OpenBolt(path string) (*Bolt, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open store %q: %w", path, err)
+	}
+	return &Bolt{db: db}, nil
+}
+
+// Get implements Store.
+func (b *Bolt) Get(namespace, key string) ([]byte, error) {
+	var out []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(namespace))
+		if bucket == nil {
+			return ErrNotFound
+		}
+		v := bucket.Get([]byte(key))
+		if v == nil {
+			return ErrNotFound
+		}
+		// Values are only valid for the life of the transaction.
+		out = append([]byte(nil), v...)
+		return nil
+	})
+	return out, err
+}
+
+// Put implements Store.
+func (b *Bolt) Put(namespace, key string, value []byte) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(namespace))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(key), value)
+	})
+}
+
+// Delete implements Store.
+func (b *Bolt) Delete(namespace, key string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(namespace))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Delete([]byte(key))
+	})
+}
+
+// List implements Store.
+func (b *Bolt) List(namespace, prefix string) ([]KV, error) {
+	var out []KV
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(namespace))
+		if bucket == nil {
+			return nil
+		}
+		c := bucket.Cursor()
+		p := []byte(prefix)
+		for k, v := c.Seek(p); k != nil && bytes.HasPrefix(k, p); k, v = c.Next() {
+			out = append(out, KV{Key: string(k), Value: append([]byte(nil), v...)})
+		}
+		return nil
+	})
+	return out, err
+}
+
+// Incr implements Store.
+func (b *Bolt) Incr(namespace, key string, delta int64) (int64, error) {
+	var n int64
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(namespace))
+		if err != nil {
+			return err
+		}
+		var v []byte
+		v, n, err = incr(bucket.Get([]byte(key)), delta)
+		if err != nil {
+			return fmt.Errorf("unable to increment %q: %w", key, err)
+		}
+		return bucket.Put([]byte(key), v)
+	})
+	return n, err
+}
+
+// Close implements Store.
+func (b *Bolt) Close() error {
+	return b.db.Close()
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Memory is a Store which only keeps data in memory, losing it on restart. Zero value is safe for
+// use.
+type Memory struct {
+	mu   sync.RWMutex
+	data map[string]map[string][]byte
+}
+
+// NewMemory returns a pointer to an empty Memory store.
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// Get implements Store.
+func (m *Memory) Get(namespace, key string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	v, ok := m.data[namespace][key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), v...), nil
+}
+
+// Put implements Store.
+func (m *Memory) Put(namespace, key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.put(namespace, key, value)
+	return nil
+}
+
+func (m *Memory) put(namespace, key string, value []byte) {
+	if m.data == nil {
+		m.data = map[string]map[string][]byte{}
+	}
+	if m.data[namespace] == nil {
+		m.data[namespace] = map[string][]byte{}
+	}
+	m.data[namespace][key] = append([]byte(nil), value...)
+}
+
+// Delete implements Store.
+func (m *Memory) Delete(namespace, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data[namespace], key)
+	return nil
+}
+
+// List implements Store.
+func (m *Memory) List(namespace, prefix string) ([]KV, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []KV
+	for k, v := range m.data[namespace] {
+		if strings.HasPrefix(k, prefix) {
+			out = append(out, KV{Key: k, Value: append([]byte(nil), v...)})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, nil
+}
+
+// Incr implements Store.
+func (m *Memory) Incr(namespace, key string, delta int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, n, err := incr(m.data[namespace][key], delta)
+	if err != nil {
+		return 0, err
+	}
+	m.put(namespace, key, v)
+	return n, nil
+}
+
+// Close implements Store.
+func (m *Memory) Close() error { return nil }
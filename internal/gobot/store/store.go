@@ -0,0 +1,53 @@
+// Package store provides namespaced key/value storage which modules use to persist state between
+// messages and restarts.
+package store
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ErrNotFound is returned when a key does not exist within a namespace.
+var ErrNotFound = errors.New("key not found")
+
+// Store is a namespaced key/value store. Namespaces keep the keys of different modules apart and
+// are created on first use. Implementations must be safe for concurrent use.
+type Store interface {
+	// Get returns the value stored under key, or ErrNotFound.
+	Get(namespace, key string) ([]byte, error)
+	// Put stores value under key, replacing any existing value.
+	Put(namespace, key string, value []byte) error
+	// Delete removes key. Deleting a key which doesn't exist is not an error.
+	Delete(namespace, key string) error
+	// List returns every key/value pair whose key starts with prefix, sorted by key.
+	List(namespace, prefix string) ([]KV, error)
+	// Incr atomically adds delta to the integer stored under key, treating a missing key as zero,
+	// and returns the new value.
+	Incr(namespace, key string, delta int64) (int64, error)
+	// Close releases any resources held by the store.
+	Close() error
+}
+
+// KV is a single key/value pair returned by List.
+type KV struct {
+	Key   string
+	Value []byte
+}
+
+// Int parses a value written by Incr.
+func Int(value []byte) (int64, error) {
+	return strconv.ParseInt(string(value), 10, 64)
+}
+
+// incr returns the result of adding delta to an integer value written by Incr.
+func incr(value []byte, delta int64) ([]byte, int64, error) {
+	var n int64
+	if value != nil {
+		var err error
+		if n, err = Int(value); err != nil {
+			return nil, 0, err
+		}
+	}
+	n += delta
+	return []byte(strconv.FormatInt(n, 10)), n, nil
+}
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+	defer s.Close()
+
+	if _, err := s.Get("ns", "missing"); err != ErrNotFound {
+		t.Errorf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+	if err := s.Put("ns", "a/1", []byte("one")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("ns", "a/2", []byte("two")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("other", "a/3", []byte("three")); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := s.Get("ns", "a/1"); err != nil || string(v) != "one" {
+		t.Errorf("Get(a/1) = %q, %v", v, err)
+	}
+
+	kvs, err := s.List("ns", "a/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kvs) != 2 || kvs[0].Key != "a/1" || kvs[1].Key != "a/2" || string(kvs[1].Value) != "two" {
+		t.Errorf("List(a/) = %+v", kvs)
+	}
+	if kvs, err := s.List("missing", ""); err != nil || len(kvs) != 0 {
+		t.Errorf("List(missing) = %+v, %v", kvs, err)
+	}
+
+	if err := s.Delete("ns", "a/1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("ns", "a/1"); err != ErrNotFound {
+		t.Errorf("Get(a/1) after Delete error = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete("missing", "a/1"); err != nil {
+		t.Errorf("Delete(missing) = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Incr("ns", "count", 2); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n, err := s.Incr("ns", "count", -1); err != nil || n != 39 {
+		t.Errorf("Incr(count) = %v, %v, want 39", n, err)
+	}
+	if _, err := s.Incr("ns", "a/2", 1); err == nil {
+		t.Errorf("expected incrementing a non-integer to fail")
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory())
+}
+
+func TestBolt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gobot.db")
+	s, err := OpenBolt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, s)
+
+	// Data must survive reopening the file.
+	s, err = OpenBolt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if v, err := s.Get("ns", "count"); err != nil || string(v) != "39" {
+		t.Errorf("Get(count) after reopening = %q, %v", v, err)
+	}
+}
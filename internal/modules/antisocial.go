@@ -2,114 +2,205 @@ package modules
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
+	"sort"
 	"strings"
 	"text/template"
 
 	"github.com/spy16/snowman"
 
-	"github.com/mattikus/gobot/internal/gobot/slack"
+	"github.com/mattikus/gobot/internal/gobot"
+	"github.com/mattikus/gobot/internal/gobot/rich"
 )
 
-var antisocials = map[string]struct {
-	withTarget string
-	noTarget   string
-}{
+// antisocialNamespace is the store namespace holding triggers added, edited or removed at runtime,
+// under their name.
+const antisocialNamespace = "antisocial"
+
+// antisocialTrigger holds the templates for what a trigger says, with and without a target.
+type antisocialTrigger struct {
+	WithTarget string `json:"with_target"`
+	NoTarget   string `json:"no_target"`
+	// Removed marks a built in trigger which was removed at runtime.
+	Removed bool `json:"removed,omitempty"`
+}
+
+// antisocials are the built in triggers.
+var antisocials = map[string]antisocialTrigger{
 	"maul": {
-		withTarget: "{{.Subject}} mauls {{.Target}} in angry bear-like fashion.",
-		noTarget:   "{{.Subject}} RAAAHR!!",
+		WithTarget: "{{.Subject}} mauls {{.Target}} in angry bear-like fashion.",
+		NoTarget:   "{{.Subject}} RAAAHR!!",
 	},
 	"grid": {
-		withTarget: "{{.Subject}} grids {{.Target}} in angry grid-like fashion.",
-		noTarget:   "{{.Subject}} Grid?",
+		WithTarget: "{{.Subject}} grids {{.Target}} in angry grid-like fashion.",
+		NoTarget:   "{{.Subject}} Grid?",
 	},
 	"charades": {
-		withTarget: "{{.Subject}}, with finger on nose, points to {{.Target}}.",
-		noTarget:   "{{.Subject}} Sounds like...",
+		WithTarget: "{{.Subject}}, with finger on nose, points to {{.Target}}.",
+		NoTarget:   "{{.Subject}} Sounds like...",
 	},
 	"greet": {
-		withTarget: "{{.Subject}} [to {{.Target}}]: Corned beef monkey monkey monkey butt!",
-		noTarget:   "{{.Subject}} FUECKER!",
+		WithTarget: "{{.Subject}} [to {{.Target}}]: Corned beef monkey monkey monkey butt!",
+		NoTarget:   "{{.Subject}} FUECKER!",
 	},
 	"nelson": {
-		withTarget: "{{.Subject}} [to {{.Target}}]: HAW HAW!",
-		noTarget:   "{{.Subject}} I *said* HAW HAW!",
+		WithTarget: "{{.Subject}} [to {{.Target}}]: HAW HAW!",
+		NoTarget:   "{{.Subject}} I *said* HAW HAW!",
 	},
 	"ivan": {
-		withTarget: "{{.Subject}} chuckels maelvoelntly at {{.Target}}.",
-		noTarget:   "{{.Subject}} He types good.",
+		WithTarget: "{{.Subject}} chuckels maelvoelntly at {{.Target}}.",
+		NoTarget:   "{{.Subject}} He types good.",
 	},
 	"flame": {
-		withTarget: "{{.Subject}} sets {{.Target}} on fire.",
-		noTarget:   "{{.Subject}} YOU MORON! HITLER!!",
+		WithTarget: "{{.Subject}} sets {{.Target}} on fire.",
+		NoTarget:   "{{.Subject}} YOU MORON! HITLER!!",
 	},
 	"cheese": {
-		withTarget: "{{.Subject}} [to {{.Target}}]: I like cheese.",
-		noTarget:   "{{.Subject}} Behold the power of cheese!",
+		WithTarget: "{{.Subject}} [to {{.Target}}]: I like cheese.",
+		NoTarget:   "{{.Subject}} Behold the power of cheese!",
 	},
 	"chuck": {
-		withTarget: "{{.Subject}} wishes {{.Target}} a happy birthday.  And then this big hairy mouse with very bored eyes comes in and dances with {{.Target}}.",
-		noTarget:   "{{.Subject}} all the time singing music (think chipmonks on speed) broadcast in mono over the sound system with peak levels that make the speakers crackle",
+		WithTarget: "{{.Subject}} wishes {{.Target}} a happy birthday.  And then this big hairy mouse with very bored eyes comes in and dances with {{.Target}}.",
+		NoTarget:   "{{.Subject}} all the time singing music (think chipmonks on speed) broadcast in mono over the sound system with peak levels that make the speakers crackle",
 	},
 	"fire": {
-		withTarget: "{{.Target}}: You're fired.",
-		noTarget:   "EVACUATE THE BUILDING!",
+		WithTarget: "{{.Target}}: You're fired.",
+		NoTarget:   "EVACUATE THE BUILDING!",
 	},
 	"pound": {
-		withTarget: "{{.Subject}} pounds and pounds {{.Target}} with a shovel.",
-		noTarget:   "{{.Subject}} I'll take 'Things you just want to pound and pound with a shovel' for $300, Alex.'",
+		WithTarget: "{{.Subject}} pounds and pounds {{.Target}} with a shovel.",
+		NoTarget:   "{{.Subject}} I'll take 'Things you just want to pound and pound with a shovel' for $300, Alex.'",
 	},
 	"eye": {
-		withTarget: "{{.Subject}} eyes {{.Target}} warily.",
-		noTarget:   "{{.Subject}} nay.",
+		WithTarget: "{{.Subject}} eyes {{.Target}} warily.",
+		NoTarget:   "{{.Subject}} nay.",
 	},
 	"thank": {
-		withTarget: "{{.Subject}} [to {{.Target}}]: Thanks {{.Target}}! BOK BOK!",
-		noTarget:   "{{.Subject}} I DON'T KNOW WHAT TO SAY WHEN YOU SAY THAT.",
+		WithTarget: "{{.Subject}} [to {{.Target}}]: Thanks {{.Target}}! BOK BOK!",
+		NoTarget:   "{{.Subject}} I DON'T KNOW WHAT TO SAY WHEN YOU SAY THAT.",
 	},
 	"back": {
-		withTarget: "{{.Subject}} slowly backs away from {{.Target}}, careful not to make eye contact.",
-		noTarget:   "{{.Subject}} Little in the middle but ya got much...",
+		WithTarget: "{{.Subject}} slowly backs away from {{.Target}}, careful not to make eye contact.",
+		NoTarget:   "{{.Subject}} Little in the middle but ya got much...",
 	},
 	"peer": {
-		withTarget: "{{.Subject}} peers at {{.Target}} suspiciously.",
-		noTarget:   "{{.Subject}} peers at nothing in particular for no good reason.",
+		WithTarget: "{{.Subject}} peers at {{.Target}} suspiciously.",
+		NoTarget:   "{{.Subject}} peers at nothing in particular for no good reason.",
 	},
 }
 
-func triggers() []string {
+// storedTriggers returns the triggers added, edited or removed at runtime.
+func storedTriggers() (map[string]antisocialTrigger, error) {
+	out := map[string]antisocialTrigger{}
+	if deps.Store == nil {
+		return out, nil
+	}
+	kvs, err := deps.Store.List(antisocialNamespace, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, kv := range kvs {
+		var t antisocialTrigger
+		if err := json.Unmarshal(kv.Value, &t); err != nil {
+			return nil, fmt.Errorf("invalid antisocial trigger %q: %w", kv.Key, err)
+		}
+		out[kv.Key] = t
+	}
+	return out, nil
+}
+
+// allTriggers returns every trigger which can be used, with changes made at runtime applied to the
+// built in ones.
+func allTriggers() (map[string]antisocialTrigger, error) {
+	stored, err := storedTriggers()
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]antisocialTrigger{}
+	for name, t := range antisocials {
+		out[name] = t
+	}
+	for name, t := range stored {
+		if t.Removed {
+			delete(out, name)
+		} else {
+			out[name] = t
+		}
+	}
+	return out, nil
+}
+
+// triggers returns the names of every trigger, sorted.
+func triggers() ([]string, error) {
+	ts, err := allTriggers()
+	if err != nil {
+		return nil, err
+	}
 	var keys []string
-	for k := range antisocials {
+	for k := range ts {
 		keys = append(keys, k)
 	}
-	return keys
+	sort.Strings(keys)
+	return keys, nil
 }
 
-func triggerExp() string {
-	return fmt.Sprintf(`!(?P<trigger>rand|%v)\s*(?P<target>.*)?$`, strings.Join(triggers(), "|"))
+func triggerExp() (string, error) {
+	ts, err := triggers()
+	if err != nil {
+		return "", err
+	}
+	// Triggers must end at a word boundary, so that one isn't matched by another it starts with.
+	return fmt.Sprintf(`!(?P<trigger>rand|%v)\b\s*(?P<target>.*)?$`, strings.Join(ts, "|")), nil
 }
 
-func randTrigger() string {
-	ts := triggers()
-	return ts[rand.Intn(len(ts))]
+// reloadTriggers rebuilds the antisocial pattern so that it matches exactly the current triggers.
+func reloadTriggers() error {
+	exp, err := triggerExp()
+	if err != nil {
+		return err
+	}
+	return classifier.Replace("antisocial", exp)
 }
 
-func Antisocial(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+func randTrigger(r *rand.Rand) (string, error) {
+	ts, err := triggers()
+	if err != nil {
+		return "", err
+	}
+	return ts[r.Intn(len(ts))], nil
+}
+
+// antisocialData is what trigger templates are executed with.
+type antisocialData struct {
+	Subject string
+	Target  string
+}
+
+func Antisocial(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
 	t := intent.Ctx["trigger"].(string)
 	if t == "rand" {
-		t = randTrigger()
+		var err error
+		if t, err = randTrigger(gobot.Rand(ctx)); err != nil {
+			return snowman.Msg{}, err
+		}
 	}
 
-	trigger, ok := antisocials[t]
+	ts, err := allTriggers()
+	if err != nil {
+		return snowman.Msg{}, err
+	}
+	trigger, ok := ts[t]
 	if !ok {
-		return snowman.Msg{}, fmt.Errorf("can't find trigger named %q", trigger)
+		return snowman.Msg{}, fmt.Errorf("can't find trigger named %q", t)
 	}
 
-	s := trigger.noTarget
+	s := trigger.NoTarget
 	target, ok := intent.Ctx["target"].(string)
 	if ok && target != "" {
-		s = trigger.withTarget
+		s = trigger.WithTarget
 	}
 
 	tmpl, err := template.New("").Parse(s)
@@ -118,14 +209,118 @@ func Antisocial(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
 	}
 
 	body := &strings.Builder{}
-	subject := slack.AddressUser(intent.Msg.From.ID, "")
-	tmpl.Execute(body, &struct {
-		Subject string
-		Target  string
-	}{subject, target})
+	subject := rich.Mention(intent.Msg.From.ID)
+	tmpl.Execute(body, &antisocialData{subject, target})
 	return NewMsg(intent.Msg, body.String()), nil
 }
 
+// checkTemplate checks that a trigger template parses and only uses the data it's given.
+func checkTemplate(s string) error {
+	tmpl, err := template.New("").Option("missingkey=error").Parse(s)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(ioutil.Discard, &antisocialData{"<@USUBJECT>", "<@UTARGET>"})
+}
+
+// AntisocialSave implements a snowman.ProcessorFunc which adds a new trigger, or edits an existing
+// one.
+func AntisocialSave(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	name := strings.ToLower(intent.Ctx["name"].(string))
+	t := antisocialTrigger{WithTarget: intent.Ctx["with"].(string), NoTarget: intent.Ctx["without"].(string)}
+	if name == "rand" {
+		return Ephemeral(NewMsg(intent.Msg, "`rand` is reserved for picking a random trigger."), intent.Msg), nil
+	}
+	for _, s := range []string{t.WithTarget, t.NoTarget} {
+		if err := checkTemplate(s); err != nil {
+			return Ephemeral(NewMsg(intent.Msg, fmt.Sprintf(
+				"That template doesn't work: %v\nUse `{{.Subject}}` and `{{.Target}}` for who's being antisocial and at whom.", err)), intent.Msg), nil
+		}
+	}
+
+	ts, err := allTriggers()
+	if err != nil {
+		return snowman.Msg{}, err
+	}
+	_, exists := ts[name]
+	switch {
+	case intent.Ctx["op"] == "add" && exists:
+		return Ephemeral(NewMsg(intent.Msg, fmt.Sprintf("`!%v` already exists, use `antisocial edit` to change it.", name)), intent.Msg), nil
+	case intent.Ctx["op"] == "edit" && !exists:
+		return Ephemeral(NewMsg(intent.Msg, fmt.Sprintf("There's no `!%v` to edit.", name)), intent.Msg), nil
+	}
+
+	data, err := json.Marshal(t)
+	if err != nil {
+		return snowman.Msg{}, err
+	}
+	if err := deps.Store.Put(antisocialNamespace, name, data); err != nil {
+		return snowman.Msg{}, fmt.Errorf("unable to save trigger %q: %w", name, err)
+	}
+	if err := reloadTriggers(); err != nil {
+		return snowman.Msg{}, err
+	}
+	return NewMsg(intent.Msg, fmt.Sprintf("Saved `!%v`.", name)), nil
+}
+
+// AntisocialRemove implements a snowman.ProcessorFunc which removes a trigger.
+func AntisocialRemove(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	name := strings.ToLower(intent.Ctx["name"].(string))
+	ts, err := allTriggers()
+	if err != nil {
+		return snowman.Msg{}, err
+	}
+	if _, ok := ts[name]; !ok {
+		return Ephemeral(NewMsg(intent.Msg, fmt.Sprintf("There's no `!%v` to remove.", name)), intent.Msg), nil
+	}
+	if len(ts) == 1 {
+		return Ephemeral(NewMsg(intent.Msg, "That's the last trigger, so it has to stay."), intent.Msg), nil
+	}
+
+	if _, builtin := antisocials[name]; builtin {
+		data, err := json.Marshal(antisocialTrigger{Removed: true})
+		if err != nil {
+			return snowman.Msg{}, err
+		}
+		err = deps.Store.Put(antisocialNamespace, name, data)
+	} else {
+		err = deps.Store.Delete(antisocialNamespace, name)
+	}
+	if err != nil {
+		return snowman.Msg{}, fmt.Errorf("unable to remove trigger %q: %w", name, err)
+	}
+	if err := reloadTriggers(); err != nil {
+		return snowman.Msg{}, err
+	}
+	return NewMsg(intent.Msg, fmt.Sprintf("Removed `!%v`.", name)), nil
+}
+
+// flameAuthor flames whoever wrote the message reacted to.
+func flameAuthor(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	author, _ := intent.Ctx["user"].(string)
+	intent.Ctx["trigger"] = "flame"
+	intent.Ctx["target"] = rich.Mention(author)
+	return Antisocial(ctx, intent)
+}
+
 func init() {
-	Hear(triggerExp(), "antisocial", Antisocial)
+	// Patterns are rebuilt once the store is available, so only the built in triggers are known here.
+	exp, _ := triggerExp()
+	Hear(exp, "antisocial", Antisocial,
+		Usage("!<trigger> [target]", "be antisocial, optionally at someone. `!rand` picks a trigger for you"),
+		Details(func() string {
+			ts, err := triggers()
+			if err != nil {
+				return ""
+			}
+			return "Triggers: " + strings.Join(ts, ", ")
+		}))
+	React("fire", "antisocial.fire", flameAuthor,
+		Usage(":fire:", "flame whoever wrote the message"))
+	Reply(`^antisocial (?P<op>add|edit) (?P<name>\w+) ["“](?P<with>[^"”]*)["”] ["“](?P<without>[^"”]*)["”]$`, "antisocial.save", AntisocialSave,
+		Usage(`antisocial add|edit <trigger> "<with target>" "<without target>"`, "add or change a trigger. Templates can use `{{.Subject}}` and `{{.Target}}`"),
+		RequireRole(RoleAdmin))
+	Reply(`^antisocial remove (?P<name>\w+)$`, "antisocial.remove", AntisocialRemove,
+		Usage("antisocial remove <trigger>", "remove a trigger"), RequireRole(RoleAdmin))
+	onRegister(reloadTriggers)
 }
@@ -0,0 +1,97 @@
+package modules
+
+import (
+	"testing"
+
+	"github.com/mattikus/gobot/internal/gobot/store"
+)
+
+func TestAntisocial(t *testing.T) {
+	conv := startConversation(t)
+	alice := conv.User("alice")
+
+	alice.Says("!maul bob")
+	conv.Expect(`^<@UALICE> mauls bob in angry bear-like fashion\.$`)
+
+	alice.Says("!maul")
+	conv.Expect(`^<@UALICE> RAAAHR!!$`)
+
+	alice.Says("!fire bob")
+	conv.Expect(`^bob: You're fired\.$`)
+
+	alice.Says("!rand")
+	conv.Expect(`.+`)
+
+	alice.Says("!nope")
+	conv.ExpectNothing()
+}
+
+func TestAntisocialReaction(t *testing.T) {
+	conv := startConversation(t)
+	alice, bob := conv.User("alice"), conv.User("bob")
+
+	alice.Reacts(bob.User(), "1.000001", "fire")
+	conv.Expect(`^<@UALICE> sets <@UBOB> on fire\.$`)
+
+	alice.Unreacts(bob.User(), "1.000001", "fire")
+	alice.Reacts(bob.User(), "1.000001", "thumbsup")
+	conv.ExpectNothing()
+}
+
+func TestAntisocialTriggers(t *testing.T) {
+	st := store.NewMemory()
+	conv := startConversationWith(t, Deps{Store: st, Owners: []string{"UALICE"}})
+	alice, bob := conv.User("alice"), conv.User("bob")
+
+	bob.Tells(`antisocial add bonk "{{.Subject}} bonks {{.Target}}" "{{.Subject}} BONK"`)
+	conv.Expect(`^Sorry, only admins can do that\.$`)
+
+	alice.Tells(`antisocial add bonk "{{.Subject}} bonks {{.Target}}" "{{.Subject}} BONK"`)
+	conv.Expect("^Saved `!bonk`\\.$")
+	bob.Says("!bonk alice")
+	conv.Expect(`^<@UBOB> bonks alice$`)
+	bob.Says("!bonk")
+	conv.Expect(`^<@UBOB> BONK$`)
+
+	alice.Tells(`antisocial add bonk "{{.Subject}} bonks {{.Target}}" "{{.Subject}} BONK"`)
+	conv.Expect(`already exists`)
+	alice.Tells(`antisocial edit bonk “{{.Subject}} bonks {{.Target}} twice” “{{.Subject}} BONK BONK”`)
+	conv.Expect("^Saved `!bonk`\\.$")
+	bob.Says("!bonk alice")
+	conv.Expect(`^<@UBOB> bonks alice twice$`)
+
+	// Triggers starting with another trigger's name still fire.
+	alice.Tells(`antisocial add thanks "{{.Subject}} thanks {{.Target}} a lot" "{{.Subject}} says thanks a lot"`)
+	conv.Expect("^Saved `!thanks`\\.$")
+	bob.Says("!thanks alice")
+	conv.Expect(`^<@UBOB> thanks alice a lot$`)
+	bob.Says("!thank alice")
+	conv.Expect(`^<@UBOB> \[to alice\]: Thanks alice! BOK BOK!$`)
+
+	alice.Tells(`antisocial add boop "{{.Subject}} boops {{.Target" "{{.Subject}} boop"`)
+	conv.Expect(`^That template doesn't work`)
+	alice.Tells(`antisocial add boop "{{.Subject}} boops {{.Victim}}" "{{.Subject}} boop"`)
+	conv.Expect(`^That template doesn't work`)
+	alice.Tells(`antisocial edit boop "{{.Subject}} boops {{.Target}}" "{{.Subject}} boop"`)
+	conv.Expect("no `!boop` to edit")
+	alice.Tells(`antisocial add rand "{{.Subject}}" "{{.Subject}}"`)
+	conv.Expect(`reserved`)
+
+	alice.Tells("antisocial remove maul")
+	conv.Expect("^Removed `!maul`\\.$")
+	bob.Says("!maul alice")
+	conv.ExpectNothing()
+	alice.Tells("help antisocial")
+	conv.Expect(`Triggers: .*bonk`)
+
+	// Triggers are persisted.
+	other := startConversationWith(t, Deps{Store: st, Owners: []string{"UALICE"}})
+	other.User("bob").Says("!bonk")
+	other.Expect(`^<@UBOB> BONK BONK$`)
+	other.User("bob").Says("!maul")
+	other.ExpectNothing()
+	other.User("alice").Tells("antisocial remove bonk")
+	other.Expect("^Removed `!bonk`\\.$")
+	other.User("bob").Says("!bonk")
+	other.ExpectNothing()
+}
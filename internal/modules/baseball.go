@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"math/rand"
 
-	"github.com/slack-go/slack"
 	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot"
+	"github.com/mattikus/gobot/internal/gobot/rich"
 )
 
 const playerURLBase = "http://www.mcs.anl.gov/~acherry/bb-images"
@@ -35,23 +37,23 @@ var playerNames = []string{
 	"Chicken Nutlugget",
 }
 
-func randPlayer() string {
-	return playerNames[rand.Intn(len(playerNames))]
+func randPlayer(r *rand.Rand) string {
+	return playerNames[r.Intn(len(playerNames))]
 }
 
-func randImg() string {
-	return fmt.Sprintf("%v/%v.jpg", playerURLBase, rand.Intn(860)+1)
+func randImg(r *rand.Rand) string {
+	return fmt.Sprintf("%v/%v.jpg", playerURLBase, r.Intn(860)+1)
 }
 
 func init() {
-	Reply(`baseball(?: me)?`, "baseball.player", func(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
-		player := fmt.Sprintf(">*Player:* %v", randPlayer())
-		img := randImg()
+	Reply(`baseball(?: me)?`, "baseball.player", func(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
+		r := gobot.Rand(ctx)
+		player := fmt.Sprintf(">*Player:* %v", randPlayer(r))
+		img := randImg(r)
 		body := fmt.Sprintf("%v\n%v", player, img)
 		return NewMsg(intent.Msg, body,
-			slack.NewImageBlock(img, player, "", nil),
-			slack.NewSectionBlock(
-				slack.NewTextBlockObject(slack.MarkdownType, player, false, false), nil, nil),
+			rich.Image{URL: img, Alt: player},
+			rich.Text{Markdown: player},
 		), nil
-	})
+	}, Usage("baseball me", "introduce a random baseball player"))
 }
@@ -0,0 +1,14 @@
+package modules
+
+import "testing"
+
+func TestBaseball(t *testing.T) {
+	conv := startConversation(t)
+	alice := conv.User("alice")
+
+	alice.Tells("baseball me")
+	conv.Expect(`^>\*Player:\* \S.+\nhttp://www\.mcs\.anl\.gov/~acherry/bb-images/\d+\.jpg$`)
+
+	alice.Says("baseball me")
+	conv.ExpectNothing()
+}
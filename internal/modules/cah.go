@@ -0,0 +1,557 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot"
+	"github.com/mattikus/gobot/internal/gobot/attr"
+	"github.com/mattikus/gobot/internal/gobot/rich"
+)
+
+const (
+	// cahHandSize is the number of white cards each player holds.
+	cahHandSize = 10
+	// cahMinPlayers is the number of players required to deal a round: a czar and at least two
+	// players submitting cards.
+	cahMinPlayers = 3
+	// cahDefaultGoal is the score needed to win a game when none is given to `cah start`.
+	cahDefaultGoal = 5
+)
+
+// cahRevealDelay is how long the czar's pick is kept secret for by default, to build suspense. It
+// can be changed with the "reveal_delay" setting.
+var cahRevealDelay = 2 * time.Second
+
+// whiteDeck is a shuffled pile of white cards which are drawn without replacement. Played cards
+// are discarded and only shuffled back in once the deck runs out.
+type whiteDeck struct {
+	cards   []string
+	discard []string
+}
+
+func newWhiteDeck(r *rand.Rand, cards []string) *whiteDeck {
+	d := &whiteDeck{cards: append([]string(nil), cards...)}
+	r.Shuffle(len(d.cards), func(i, j int) { d.cards[i], d.cards[j] = d.cards[j], d.cards[i] })
+	return d
+}
+
+// draw removes up to count cards from the top of the deck.
+func (d *whiteDeck) draw(r *rand.Rand, count int) []string {
+	if len(d.cards) < count && len(d.discard) > 0 {
+		r.Shuffle(len(d.discard), func(i, j int) { d.discard[i], d.discard[j] = d.discard[j], d.discard[i] })
+		d.cards = append(d.cards, d.discard...)
+		d.discard = nil
+	}
+	if count > len(d.cards) {
+		count = len(d.cards)
+	}
+	out := d.cards[:count:count]
+	d.cards = d.cards[count:]
+	return out
+}
+
+// blackDeck is a shuffled pile of black cards which are drawn without replacement, starting over
+// with a fresh shuffle once every card has been played.
+type blackDeck struct {
+	cards []*blackCard
+}
+
+func (d *blackDeck) draw(r *rand.Rand) *blackCard {
+	if len(d.cards) == 0 {
+		d.cards = append([]*blackCard(nil), cardData.Black...)
+		r.Shuffle(len(d.cards), func(i, j int) { d.cards[i], d.cards[j] = d.cards[j], d.cards[i] })
+	}
+	card := d.cards[0]
+	d.cards = d.cards[1:]
+	return card
+}
+
+type cahPlayer struct {
+	user  snowman.User
+	hand  []string
+	score int
+}
+
+// cahGame holds the state of a single Cards Against Humanity game running in a channel.
+type cahGame struct {
+	channel string
+	thread  string
+	goal    int
+	players []*cahPlayer
+	white   *whiteDeck
+	black   blackDeck
+
+	// Round state. A round is in progress while card is set, and is being judged once every
+	// submission is in and judging holds the anonymised order they were shown in. czar is -1 until
+	// the first round, so that whoever started the game is its first czar.
+	round       int
+	czar        int
+	card        *blackCard
+	submissions map[string][]string
+	judging     []string
+}
+
+func (g *cahGame) player(id string) *cahPlayer {
+	for _, p := range g.players {
+		if p.user.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// announce creates a message to the game's channel, in the thread it was started in if any, no
+// matter where the message it replies to was sent.
+func (g *cahGame) announce(replyTo snowman.Msg, body string) snowman.Msg {
+	msg := NewMsg(replyTo, body)
+	msg.Attribs[attr.Channel] = g.channel
+	msg.Attribs[attr.Thread] = g.thread
+	return msg
+}
+
+func (g *cahGame) czarPlayer() *cahPlayer {
+	return g.players[g.czar%len(g.players)]
+}
+
+// deal tops up a player's hand from the deck.
+func (g *cahGame) deal(r *rand.Rand, p *cahPlayer) {
+	p.hand = append(p.hand, g.white.draw(r, cahHandSize-len(p.hand))...)
+}
+
+// startRound advances the czar, draws a new black card and tops up every hand.
+func (g *cahGame) startRound(r *rand.Rand) {
+	g.round++
+	g.czar = (g.czar + 1) % len(g.players)
+	g.card = g.black.draw(r)
+	g.submissions = map[string][]string{}
+	g.judging = nil
+	for _, p := range g.players {
+		g.deal(r, p)
+	}
+}
+
+// endRound discards everything played this round and leaves the game waiting for a new round.
+func (g *cahGame) endRound() {
+	for _, cards := range g.submissions {
+		g.white.discard = append(g.white.discard, cards...)
+	}
+	g.card = nil
+	g.submissions = nil
+	g.judging = nil
+}
+
+// waitingOn returns the players who still need to submit cards this round.
+func (g *cahGame) waitingOn() []*cahPlayer {
+	var out []*cahPlayer
+	czar := g.czarPlayer()
+	for _, p := range g.players {
+		if _, ok := g.submissions[p.user.ID]; !ok && p != czar {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// scores returns a summary of every player's score, highest first.
+func (g *cahGame) scores() string {
+	players := append([]*cahPlayer(nil), g.players...)
+	sort.SliceStable(players, func(i, j int) bool { return players[i].score > players[j].score })
+	var lines []string
+	for _, p := range players {
+		lines = append(lines, fmt.Sprintf("%v: %v", mention(p.user), p.score))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// games holds every running game keyed by channel, along with which channel each player is in so
+// that cards can be played privately from a DM.
+var games = struct {
+	sync.Mutex
+	byChannel map[string]*cahGame
+	byPlayer  map[string]*cahGame
+}{
+	byChannel: map[string]*cahGame{},
+	byPlayer:  map[string]*cahGame{},
+}
+
+func mention(u snowman.User) string {
+	return rich.Mention(u.ID)
+}
+
+// sendHand privately sends a player their current hand.
+func sendHand(ctx context.Context, g *cahGame, p *cahPlayer) error {
+	var lines []string
+	for idx, c := range p.hand {
+		lines = append(lines, fmt.Sprintf("*%v.* %v", idx+1, c))
+	}
+	body := "*Your hand:*\n" + strings.Join(lines, "\n")
+	if g.card != nil && p != g.czarPlayer() {
+		body = fmt.Sprintf("*Black card:* %v\n%v\nPlay with `cah play <number>`", blackText(g.card), body)
+	}
+	return gobot.Emit(ctx, NewDM(p.user, body))
+}
+
+func blackText(card *blackCard) string {
+	if card.Pick > 1 {
+		return fmt.Sprintf("*(Pick %v)* %v", card.Pick, card.Text)
+	}
+	return card.Text
+}
+
+// beginRound starts a new round and announces it, sending each player their refreshed hand.
+func beginRound(ctx context.Context, g *cahGame) (string, error) {
+	g.startRound(gobot.Rand(ctx))
+	for _, p := range g.players {
+		if err := sendHand(ctx, g, p); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("*Round %v!* %v is the card czar.\n>%v\nCheck your DMs and play your cards with `cah play <number>`.",
+		g.round, mention(g.czarPlayer().user), blackText(g.card)), nil
+}
+
+func cahStart(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	goal := cahDefaultGoal
+	if n, ok := intent.Ctx["goal"].(string); ok && n != "" {
+		var err error
+		if goal, err = strconv.Atoi(n); err != nil || goal < 1 {
+			return Ephemeral(NewMsg(intent.Msg, fmt.Sprintf("%q isn't a valid score to play to.", n)), intent.Msg), nil
+		}
+	}
+
+	channel := channelOf(intent.Msg)
+	games.Lock()
+	defer games.Unlock()
+	if _, ok := games.byChannel[channel]; ok {
+		return Ephemeral(NewMsg(intent.Msg, "There's already a game running here, join it with `cah join`."), intent.Msg), nil
+	}
+	if _, ok := games.byPlayer[intent.Msg.From.ID]; ok {
+		return Ephemeral(NewMsg(intent.Msg, "You're already playing in another channel."), intent.Msg), nil
+	}
+
+	r := gobot.Rand(ctx)
+	g := &cahGame{channel: channel, goal: goal, czar: -1, white: newWhiteDeck(r, cardData.White)}
+	g.thread, _ = intent.Msg.Attribs[attr.Thread].(string)
+	p := &cahPlayer{user: intent.Msg.From}
+	g.players = append(g.players, p)
+	games.byChannel[channel] = g
+	games.byPlayer[p.user.ID] = g
+	g.deal(r, p)
+	if err := sendHand(ctx, g, p); err != nil {
+		return snowman.Msg{}, err
+	}
+	return NewMsg(intent.Msg, fmt.Sprintf(
+		"%v started a game of Cards Against Humanity, first to %v wins! Join with `cah join`, then `cah deal` once there are at least %v players.",
+		mention(intent.Msg.From), goal, cahMinPlayers)), nil
+}
+
+func cahJoin(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	games.Lock()
+	defer games.Unlock()
+	g, ok := games.byChannel[channelOf(intent.Msg)]
+	if !ok {
+		return Ephemeral(NewMsg(intent.Msg, "There's no game running here, start one with `cah start`."), intent.Msg), nil
+	}
+	if other, ok := games.byPlayer[intent.Msg.From.ID]; ok {
+		if other == g {
+			return Ephemeral(NewMsg(intent.Msg, "You're already in this game."), intent.Msg), nil
+		}
+		return Ephemeral(NewMsg(intent.Msg, "You're already playing in another channel."), intent.Msg), nil
+	}
+
+	p := &cahPlayer{user: intent.Msg.From}
+	g.players = append(g.players, p)
+	games.byPlayer[p.user.ID] = g
+	g.deal(gobot.Rand(ctx), p)
+	if err := sendHand(ctx, g, p); err != nil {
+		return snowman.Msg{}, err
+	}
+	return NewMsg(intent.Msg, fmt.Sprintf("%v joined the game, that makes %v players.", mention(p.user), len(g.players))), nil
+}
+
+func cahLeave(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	games.Lock()
+	defer games.Unlock()
+	g, ok := games.byPlayer[intent.Msg.From.ID]
+	if !ok {
+		return Ephemeral(NewMsg(intent.Msg, "You're not in a game."), intent.Msg), nil
+	}
+
+	wasCzar := g.card != nil && g.czarPlayer().user.ID == intent.Msg.From.ID
+	for idx, p := range g.players {
+		if p.user.ID == intent.Msg.From.ID {
+			g.white.discard = append(g.white.discard, p.hand...)
+			g.players = append(g.players[:idx], g.players[idx+1:]...)
+			if idx < g.czar {
+				g.czar--
+			}
+			break
+		}
+	}
+	delete(games.byPlayer, intent.Msg.From.ID)
+	delete(g.submissions, intent.Msg.From.ID)
+
+	body := fmt.Sprintf("%v left the game.", mention(intent.Msg.From))
+	switch {
+	case len(g.players) == 0:
+		delete(games.byChannel, g.channel)
+		body += " Nobody's left, so the game is over."
+	case g.card != nil && len(g.players) < cahMinPlayers:
+		g.endRound()
+		body += fmt.Sprintf(" There aren't enough players left, `cah deal` once there are %v again.", cahMinPlayers)
+	case wasCzar:
+		g.endRound()
+		g.czar--
+		round, err := beginRound(ctx, g)
+		if err != nil {
+			return snowman.Msg{}, err
+		}
+		body += " They were the czar, so let's start over.\n" + round
+	case g.card != nil && g.judging == nil && len(g.waitingOn()) == 0:
+		body += "\n" + judge(gobot.Rand(ctx), g)
+	}
+	// Leaving from a DM still needs to be announced to the game's channel.
+	return g.announce(intent.Msg, body), nil
+}
+
+func cahDeal(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	games.Lock()
+	defer games.Unlock()
+	g, ok := games.byChannel[channelOf(intent.Msg)]
+	if !ok {
+		return Ephemeral(NewMsg(intent.Msg, "There's no game running here, start one with `cah start`."), intent.Msg), nil
+	}
+	if g.card != nil {
+		return Ephemeral(NewMsg(intent.Msg, "There's already a round in progress."), intent.Msg), nil
+	}
+	if len(g.players) < cahMinPlayers {
+		return Ephemeral(NewMsg(intent.Msg, fmt.Sprintf("You need at least %v players to deal, there are only %v.", cahMinPlayers, len(g.players))), intent.Msg), nil
+	}
+	body, err := beginRound(ctx, g)
+	if err != nil {
+		return snowman.Msg{}, err
+	}
+	return NewMsg(intent.Msg, body), nil
+}
+
+// judge anonymises the submissions for the czar and returns the announcement listing them.
+func judge(r *rand.Rand, g *cahGame) string {
+	for id := range g.submissions {
+		g.judging = append(g.judging, id)
+	}
+	// Map iteration order is random, so sort to make the order depend only on r.
+	sort.Strings(g.judging)
+	r.Shuffle(len(g.judging), func(i, j int) { g.judging[i], g.judging[j] = g.judging[j], g.judging[i] })
+
+	lines := []string{fmt.Sprintf("All cards are in! %v, pick a winner with `cah pick <number>`.\n>%v",
+		mention(g.czarPlayer().user), blackText(g.card))}
+	for idx, id := range g.judging {
+		lines = append(lines, fmt.Sprintf("*%v.* %v", idx+1, strings.Join(g.submissions[id], " / ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func cahPlay(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	games.Lock()
+	defer games.Unlock()
+	g, ok := games.byPlayer[intent.Msg.From.ID]
+	if !ok {
+		return Ephemeral(NewMsg(intent.Msg, "You're not in a game."), intent.Msg), nil
+	}
+	p := g.player(intent.Msg.From.ID)
+	switch {
+	case g.card == nil:
+		return Ephemeral(NewMsg(intent.Msg, "There's no round in progress."), intent.Msg), nil
+	case g.judging != nil:
+		return Ephemeral(NewMsg(intent.Msg, "Too late, the czar is already judging this round."), intent.Msg), nil
+	case p == g.czarPlayer():
+		return Ephemeral(NewMsg(intent.Msg, "You're the czar this round, sit back and wait for the cards to come in."), intent.Msg), nil
+	}
+	if _, ok := g.submissions[p.user.ID]; ok {
+		return Ephemeral(NewMsg(intent.Msg, "You've already played this round."), intent.Msg), nil
+	}
+
+	args, _ := intent.Ctx["cards"].(string)
+	picks := strings.Fields(args)
+	if len(picks) != g.card.Pick {
+		return Ephemeral(NewMsg(intent.Msg, fmt.Sprintf("This black card needs exactly %v card(s).", g.card.Pick)), intent.Msg), nil
+	}
+	seen := map[int]bool{}
+	var idxs []int
+	for _, pick := range picks {
+		n, err := strconv.Atoi(pick)
+		if err != nil || n < 1 || n > len(p.hand) || seen[n] {
+			return Ephemeral(NewMsg(intent.Msg, fmt.Sprintf("%q isn't a card in your hand.", pick)), intent.Msg), nil
+		}
+		seen[n] = true
+		idxs = append(idxs, n-1)
+	}
+
+	var played []string
+	for _, idx := range idxs {
+		played = append(played, p.hand[idx])
+	}
+	var hand []string
+	for idx, c := range p.hand {
+		if !seen[idx+1] {
+			hand = append(hand, c)
+		}
+	}
+	p.hand = hand
+	g.submissions[p.user.ID] = played
+
+	reply := g.announce(intent.Msg, fmt.Sprintf("%v played their cards.", mention(p.user)))
+	if waiting := g.waitingOn(); len(waiting) == 0 {
+		reply.Body += "\n" + judge(gobot.Rand(ctx), g)
+	} else {
+		reply.Body += fmt.Sprintf(" Waiting on %v more.", len(waiting))
+	}
+	return reply, nil
+}
+
+func cahPick(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	// The winner is recorded before the drumroll, and the lock released while it plays out so the
+	// rest of the game isn't held up waiting for it.
+	games.Lock()
+	g, ok := games.byPlayer[intent.Msg.From.ID]
+	if !ok {
+		games.Unlock()
+		return Ephemeral(NewMsg(intent.Msg, "You're not in a game."), intent.Msg), nil
+	}
+	if g.judging == nil {
+		games.Unlock()
+		return Ephemeral(NewMsg(intent.Msg, "There's nothing to judge yet."), intent.Msg), nil
+	}
+	if g.czarPlayer().user.ID != intent.Msg.From.ID {
+		games.Unlock()
+		return Ephemeral(NewMsg(intent.Msg, "Only the card czar gets to pick the winner."), intent.Msg), nil
+	}
+	arg, _ := intent.Ctx["winner"].(string)
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(g.judging) {
+		games.Unlock()
+		return Ephemeral(NewMsg(intent.Msg, fmt.Sprintf("%q isn't one of the submissions.", arg)), intent.Msg), nil
+	}
+
+	winner := g.player(g.judging[n-1])
+	if winner == nil {
+		games.Unlock()
+		return Ephemeral(NewMsg(intent.Msg, "That player has left the game, pick another."), intent.Msg), nil
+	}
+	winner.score++
+	body := fmt.Sprintf("%v wins the round with: %v\n*Scores:*\n%v",
+		mention(winner.user), strings.Join(g.submissions[winner.user.ID], " / "), g.scores())
+	g.endRound()
+	won := winner.score >= g.goal
+	if won {
+		for _, p := range g.players {
+			delete(games.byPlayer, p.user.ID)
+		}
+		delete(games.byChannel, g.channel)
+	}
+	games.Unlock()
+
+	if err := gobot.Emit(ctx, g.announce(intent.Msg, "The czar has chosen... :drum_with_drumsticks:")); err != nil {
+		return snowman.Msg{}, err
+	}
+	delay, _ := time.ParseDuration(deps.Settings.option("cah", "reveal_delay", cahRevealDelay.String()))
+	if err := gobot.Pause(ctx, delay); err != nil {
+		return snowman.Msg{}, err
+	}
+
+	reply := g.announce(intent.Msg, body)
+	if won {
+		reply.Body += fmt.Sprintf("\n:trophy: %v wins the game!", mention(winner.user))
+		// Games played in a thread still deserve their winner being shouted from the rooftops.
+		return Broadcast(reply), nil
+	}
+
+	// The game may have been stopped, or a round dealt, while the czar kept everyone waiting.
+	games.Lock()
+	defer games.Unlock()
+	switch {
+	case games.byChannel[g.channel] != g || g.card != nil:
+		return reply, nil
+	case len(g.players) < cahMinPlayers:
+		reply.Body += fmt.Sprintf("\nThere aren't enough players left, `cah deal` once there are %v again.", cahMinPlayers)
+		return reply, nil
+	}
+	round, err := beginRound(ctx, g)
+	if err != nil {
+		return snowman.Msg{}, err
+	}
+	reply.Body += "\n" + round
+	return reply, nil
+}
+
+func cahHand(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	games.Lock()
+	defer games.Unlock()
+	g, ok := games.byPlayer[intent.Msg.From.ID]
+	if !ok {
+		return Ephemeral(NewMsg(intent.Msg, "You're not in a game."), intent.Msg), nil
+	}
+	if err := sendHand(ctx, g, g.player(intent.Msg.From.ID)); err != nil {
+		return snowman.Msg{}, err
+	}
+	return Ephemeral(NewMsg(intent.Msg, "Sent you your hand."), intent.Msg), nil
+}
+
+func cahScores(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	games.Lock()
+	defer games.Unlock()
+	g, ok := games.byChannel[channelOf(intent.Msg)]
+	if !ok {
+		g, ok = games.byPlayer[intent.Msg.From.ID]
+	}
+	if !ok {
+		return Ephemeral(NewMsg(intent.Msg, "There's no game running here."), intent.Msg), nil
+	}
+	body := fmt.Sprintf("*First to %v wins. Scores:*\n%v", g.goal, g.scores())
+	if g.card != nil && g.judging == nil {
+		var waiting []string
+		for _, p := range g.waitingOn() {
+			waiting = append(waiting, mention(p.user))
+		}
+		body += fmt.Sprintf("\nWaiting on cards from: %v", strings.Join(waiting, ", "))
+	}
+	return NewMsg(intent.Msg, body, rich.Text{Markdown: body}), nil
+}
+
+func cahStop(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	games.Lock()
+	defer games.Unlock()
+	g, ok := games.byChannel[channelOf(intent.Msg)]
+	if !ok {
+		return Ephemeral(NewMsg(intent.Msg, "There's no game running here."), intent.Msg), nil
+	}
+	for _, p := range g.players {
+		delete(games.byPlayer, p.user.ID)
+	}
+	delete(games.byChannel, g.channel)
+	return NewMsg(intent.Msg, fmt.Sprintf("Game over! Final scores:\n%v", g.scores())), nil
+}
+
+func init() {
+	declareOption("cah", "reveal_delay", checkDuration)
+	Reply(`^cah start(?: to (?P<goal>\d+))?$`, "cah.start", cahStart,
+		Usage("cah start [to <score>]", "start a game of Cards Against Humanity in this channel"))
+	Reply(`^cah join$`, "cah.join", cahJoin, Usage("cah join", "join the game in this channel"))
+	Reply(`^cah leave$`, "cah.leave", cahLeave, Usage("cah leave", "leave the game you're playing"))
+	Reply(`^cah deal$`, "cah.deal", cahDeal, Usage("cah deal", "deal the first round once enough players have joined"))
+	Reply(`^cah play (?P<cards>[\d\s]+)$`, "cah.play", cahPlay,
+		Usage("cah play <number>...", "play cards from your hand, best sent as a DM"))
+	Reply(`^cah pick (?P<winner>\d+)$`, "cah.pick", cahPick, Usage("cah pick <number>", "pick the winning submission as the czar"))
+	Reply(`^cah hand$`, "cah.hand", cahHand, Usage("cah hand", "send your hand to you again"))
+	Reply(`^cah scores?$`, "cah.scores", cahScores, Usage("cah scores", "show the scores of the current game"))
+	Reply(`^cah stop$`, "cah.stop", cahStop, Usage("cah stop", "end the game in this channel"))
+}
@@ -0,0 +1,124 @@
+package modules
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot/gobottest"
+)
+
+func TestWhiteDeck(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	d := newWhiteDeck(r, []string{"a", "b", "c"})
+	got := d.draw(r, 2)
+	got = append(got, d.draw(r, 2)...)
+	if len(got) != 3 {
+		t.Fatalf("expected the deck to run out after 3 cards, got %q", got)
+	}
+	seen := map[string]bool{}
+	for _, c := range got {
+		if seen[c] {
+			t.Errorf("card %q was drawn twice", c)
+		}
+		seen[c] = true
+	}
+
+	d.discard = got
+	if again := d.draw(r, 3); len(again) != 3 {
+		t.Errorf("expected discards to be shuffled back in, got %q", again)
+	}
+}
+
+func TestCAHCzar(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	g := &cahGame{czar: -1, white: newWhiteDeck(r, cardData.White)}
+	for _, name := range []string{"alice", "bob", "carol"} {
+		g.players = append(g.players, &cahPlayer{user: snowman.User{ID: name}})
+	}
+	for _, want := range []string{"alice", "bob", "carol", "alice"} {
+		g.startRound(r)
+		if czar := g.czarPlayer().user.ID; czar != want {
+			t.Errorf("round %v: got czar %v, want %v", g.round, czar, want)
+		}
+		g.endRound()
+	}
+}
+
+func TestCAH(t *testing.T) {
+	defer func(d time.Duration) { cahRevealDelay = d }(cahRevealDelay)
+	cahRevealDelay = 0
+
+	conv := startConversation(t)
+	alice, bob, carol := conv.User("alice"), conv.User("bob"), conv.User("carol")
+	channel := gobottest.DefaultChannel
+
+	alice.Tells("cah start to 1")
+	conv.ExpectIn(alice.DMChannel(), `Your hand`)
+	conv.ExpectIn(channel, `started a game of Cards Against Humanity, first to 1 wins`)
+
+	alice.Tells("cah deal")
+	conv.ExpectIn(channel, `You need at least 3 players`)
+
+	bob.Tells("cah join")
+	conv.ExpectIn(bob.DMChannel(), `Your hand`)
+	conv.ExpectIn(channel, `joined the game, that makes 2 players`)
+	carol.Tells("cah join")
+	conv.ExpectIn(carol.DMChannel(), `Your hand`)
+	conv.ExpectIn(channel, `joined the game, that makes 3 players`)
+
+	// Whoever started the game is the first czar.
+	alice.Tells("cah deal")
+	conv.ExpectIn(alice.DMChannel(), `^\*Your hand:\*`)
+	conv.ExpectIn(bob.DMChannel(), `Black card`)
+	conv.ExpectIn(carol.DMChannel(), `Black card`)
+	conv.ExpectIn(channel, `Round 1!\* <@UALICE> is the card czar`)
+
+	games.Lock()
+	g := games.byChannel[channel]
+	pick := g.card.Pick
+	seen := map[string]bool{}
+	for _, p := range g.players {
+		if len(p.hand) != cahHandSize {
+			t.Errorf("expected %v to hold %v cards, got %v", p.user.Name, cahHandSize, len(p.hand))
+		}
+		for _, c := range p.hand {
+			if seen[c] {
+				t.Errorf("card %q was dealt twice", c)
+			}
+			seen[c] = true
+		}
+	}
+	games.Unlock()
+
+	var cards []string
+	for i := 1; i <= pick; i++ {
+		cards = append(cards, fmt.Sprint(i))
+	}
+	play := "cah play " + strings.Join(cards, " ")
+
+	alice.DMs(play)
+	conv.Expect(`You're the czar this round`)
+
+	bob.DMs(play)
+	conv.ExpectIn(channel, `<@UBOB> played their cards\. Waiting on 1 more\.`)
+	bob.DMs(play)
+	conv.Expect(`You've already played this round`)
+
+	carol.DMs(play)
+	conv.ExpectIn(channel, `All cards are in! <@UALICE>, pick a winner`)
+
+	bob.Tells("cah pick 1")
+	conv.Expect(`Only the card czar gets to pick the winner`)
+
+	alice.Tells("cah pick 1")
+	conv.ExpectIn(channel, `The czar has chosen`)
+	conv.ExpectIn(channel, `wins the round with: [\s\S]*wins the game!`)
+
+	alice.Tells("cah scores")
+	conv.Expect(`There's no game running here`)
+}
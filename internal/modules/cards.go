@@ -9,8 +9,10 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/slack-go/slack"
 	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot"
+	"github.com/mattikus/gobot/internal/gobot/rich"
 )
 
 //go:embed cah-cards-compact.json
@@ -30,29 +32,28 @@ type cards struct {
 	Black []*blackCard
 }
 
-func (c *cards) whiteCard(count int) []string {
+func (c *cards) whiteCard(r *rand.Rand, count int) []string {
 	var out []string
 	for i := 0; i < count; i++ {
-		out = append(out, c.White[rand.Intn(len(c.White))])
+		out = append(out, c.White[r.Intn(len(c.White))])
 	}
 	return out
 }
 
-func (c *cards) blackCard() *blackCard {
-	return c.Black[rand.Intn(len(c.Black))]
+func (c *cards) blackCard(r *rand.Rand) *blackCard {
+	return c.Black[r.Intn(len(c.Black))]
 }
 
-func fetchBlack(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
-	card := cardData.blackCard()
+func fetchBlack(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	card := cardData.blackCard(gobot.Rand(ctx))
 	msg := card.Text
 	if card.Pick > 1 {
 		msg = fmt.Sprintf("*(Pick %v)* %v", card.Pick, msg)
 	}
-	block := slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, msg, false, false), nil, nil)
-	return NewMsg(intent.Msg, msg, block), nil
+	return NewMsg(intent.Msg, msg, rich.Text{Markdown: msg}), nil
 }
 
-func fetchWhite(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+func fetchWhite(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
 	var err error
 	count := 1
 	if c, ok := intent.Ctx["count"]; ok && c != "" {
@@ -61,19 +62,38 @@ func fetchWhite(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
 			return snowman.Msg{}, err
 		}
 	}
-	cards := cardData.whiteCard(count)
-	var blocks []slack.Block
+	cards := cardData.whiteCard(gobot.Rand(ctx), count)
+	var content []rich.Block
 	for idx, c := range cards {
 		msg := ""
 		if len(cards) > 1 {
 			msg += fmt.Sprintf("*%v.* ", idx+1)
 		}
 		msg += c
-		block := slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, msg, false, false), nil, nil)
-		blocks = append(blocks, block)
+		content = append(content, rich.Text{Markdown: msg})
 	}
+	content = append(content, rich.Buttons{{ActionID: "cards.deal_again", Value: strconv.Itoa(count), Label: "Deal again"}})
+
+	return NewMsg(intent.Msg, strings.Join(cards, "\n"), content...), nil
+}
 
-	return NewMsg(intent.Msg, strings.Join(cards, "\n"), blocks...), nil
+// dealReaction deals a white card in the thread of the message reacted to.
+func dealReaction(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	msg, err := fetchWhite(ctx, intent)
+	if err != nil {
+		return snowman.Msg{}, err
+	}
+	return StartThread(msg, intent.Msg), nil
+}
+
+// dealAgain replaces a deal of white cards with a new one of the same size.
+func dealAgain(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	intent.Ctx["count"] = intent.Ctx["value"]
+	msg, err := fetchWhite(ctx, intent)
+	if err != nil {
+		return snowman.Msg{}, err
+	}
+	return Update(msg, intent.Msg), nil
 }
 
 func init() {
@@ -81,6 +101,11 @@ func init() {
 		panic(fmt.Errorf("error unmarshalling JSON cards data: %w", err))
 	}
 
-	Reply(`q(?:uestion)? card(?: me)?`, "cards.black", fetchBlack)
-	Reply(`card(?: me)? (?P<count>\d*)?`, "cards.white", fetchWhite)
+	Reply(`q(?:uestion)? card(?: me)?`, "cards.black", fetchBlack,
+		Usage("question card me", "deal a black Cards Against Humanity card"))
+	Reply(`card(?: me)? (?P<count>\d*)?`, "cards.white", fetchWhite,
+		Usage("card me [count]", "deal one or more white Cards Against Humanity cards"))
+	React("card", "cards.white.reaction", dealReaction,
+		Usage(":card:", "deal a white Cards Against Humanity card in reply to a message"))
+	Action("cards.deal_again", "cards.white.again", dealAgain)
 }
@@ -0,0 +1,56 @@
+package modules
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mattikus/gobot/internal/gobot/gobottest"
+)
+
+func TestCards(t *testing.T) {
+	conv := startConversation(t)
+	alice := conv.User("alice")
+
+	alice.Tells("question card me")
+	conv.Expect(`\S`)
+
+	alice.Tells("card me")
+	if msg := conv.Expect(`\S`); strings.Contains(msg.Body, "\n") {
+		t.Errorf("expected a single card, got %q", msg.Body)
+	}
+
+	alice.Tells("card me 3")
+	if msg := conv.Expect(`\S`); len(strings.Split(msg.Body, "\n")) != 3 {
+		t.Errorf("expected 3 cards, got %q", msg.Body)
+	}
+}
+
+func TestCardsDealAgain(t *testing.T) {
+	conv := startConversation(t)
+	alice, bob := conv.User("alice"), conv.User("bob")
+
+	alice.Tells("card me 2")
+	first := conv.Expect(`\S`)
+
+	bob.Clicks(first, "cards.deal_again", "2")
+	again := conv.Expect(`\S`)
+	if got, want := gobottest.Updated(again), gobottest.TimeStamp(first); got != want {
+		t.Errorf("expected dealing again to update message %q, updated %q", want, got)
+	}
+	if len(strings.Split(again.Body, "\n")) != 2 {
+		t.Errorf("expected 2 cards to be dealt again, got %q", again.Body)
+	}
+
+	bob.Clicks(first, "cards.unknown", "")
+	conv.ExpectNothing()
+}
+
+func TestCardsReaction(t *testing.T) {
+	conv := startConversation(t)
+	alice, bob := conv.User("alice"), conv.User("bob")
+
+	alice.Reacts(bob.User(), "1.000001", "card")
+	if msg := conv.ExpectIn(gobottest.DefaultChannel, `\S`); gobottest.Thread(msg) != "1.000001" {
+		t.Errorf("expected a card to be dealt in the thread of the message reacted to, got thread %q", gobottest.Thread(msg))
+	}
+}
@@ -9,8 +9,10 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/slack-go/slack"
 	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot"
+	"github.com/mattikus/gobot/internal/gobot/rich"
 )
 
 var urls = [3]string{
@@ -33,17 +35,17 @@ type bowl struct {
 	West []player
 }
 
-func (b *bowl) RandEast() player {
-	return b.East[rand.Intn(len(b.East))]
+func (b *bowl) RandEast(r *rand.Rand) player {
+	return b.East[r.Intn(len(b.East))]
 }
 
-func (b *bowl) RandWest() player {
-	return b.West[rand.Intn(len(b.West))]
+func (b *bowl) RandWest(r *rand.Rand) player {
+	return b.West[r.Intn(len(b.West))]
 }
 
-func (b *bowl) RandPlayer() player {
+func (b *bowl) RandPlayer(r *rand.Rand) player {
 	n := append(b.East, b.West...)
-	return n[rand.Intn(len(n))]
+	return n[r.Intn(len(n))]
 }
 
 var bowlData bowl
@@ -57,34 +59,29 @@ func fetchURL(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
 	if err != nil {
 		return snowman.Msg{}, err
 	}
-	return snowman.Msg{
-		Body:    urls[i-1],
-		Attribs: intent.Msg.Attribs,
-	}, nil
+	return NewMsg(intent.Msg, urls[i-1]), nil
 }
 
-func fetchPlayer(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+func fetchPlayer(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
 	conference, ok := intent.Ctx["conference"].(string)
 	if !ok || conference == "" {
 		return snowman.Msg{}, fmt.Errorf("unable to determine conference")
 	}
 	var p player
+	r := gobot.Rand(ctx)
 	switch strings.ToLower(conference) {
 	case "east":
-		p = bowlData.RandEast()
+		p = bowlData.RandEast(r)
 	case "west":
-		p = bowlData.RandWest()
+		p = bowlData.RandWest(r)
 	default:
-		p = bowlData.RandPlayer()
+		p = bowlData.RandPlayer(r)
 	}
 
 	body := fmt.Sprintf("*Name:* %v\n*College:* %v\n %v", p.Name, p.College, p.Image)
 	return NewMsg(intent.Msg, body,
-		slack.NewImageBlock(p.Image, p.Name, "", nil),
-		slack.NewSectionBlock(nil, []*slack.TextBlockObject{
-			slack.NewTextBlockObject(slack.MarkdownType, ">>>*Name:*\n"+p.Name, false, false),
-			slack.NewTextBlockObject(slack.MarkdownType, ">>>*College:*\n"+p.College, false, false),
-		}, nil)), nil
+		rich.Image{URL: p.Image, Alt: p.Name},
+		rich.Fields{">>>*Name:*\n" + p.Name, ">>>*College:*\n" + p.College}), nil
 }
 
 func init() {
@@ -92,6 +89,8 @@ func init() {
 		panic(fmt.Errorf("error unmarshalling JSON cards data: %w", err))
 	}
 
-	Reply(`(?P<conference>east|west|eastwest)(?: me)?$`, "eastwest.player", fetchPlayer)
-	Reply(`eastwest(?: me)? url\s*(?P<url>[123])?$`, "eastwest.url", fetchURL)
+	Reply(`(?P<conference>east|west|eastwest)(?: me)?$`, "eastwest.player", fetchPlayer,
+		Usage("east|west|eastwest me", "introduce a player from the East/West Bowl"))
+	Reply(`eastwest(?: me)? url\s*(?P<url>[123])?$`, "eastwest.url", fetchURL,
+		Usage("eastwest url [1-3]", "link one of the East/West Bowl videos"))
 }
@@ -0,0 +1,22 @@
+package modules
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/mattikus/gobot/internal/gobot/gobottest"
+)
+
+func TestEastWest(t *testing.T) {
+	conv := startConversation(t)
+	alice := conv.User("alice")
+
+	alice.Tells("east me")
+	conv.Expect(`^\*Name:\* .+\n\*College:\* .+\n http`)
+
+	alice.Tells("eastwest url 2")
+	conv.ExpectIn(gobottest.DefaultChannel, `^`+regexp.QuoteMeta(urls[1])+`$`)
+
+	alice.Tells("eastwest url")
+	conv.ExpectIn(gobottest.DefaultChannel, `^`+regexp.QuoteMeta(urls[0])+`$`)
+}
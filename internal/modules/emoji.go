@@ -7,33 +7,37 @@ import (
 	"strings"
 
 	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot"
+	"github.com/mattikus/gobot/internal/gobot/rich"
 )
 
 // emojiList is a slice of strings representing emoji names for Slack.
 type emojiList []string
 
 // rand returns a random emoji from the emojiList.
-func (e emojiList) rand(count int) []string {
+func (e emojiList) rand(r *rand.Rand, count int) []string {
 	var out []string
 	for i := 0; i < count; i++ {
-		out = append(out, e[rand.Intn(len(e))])
+		out = append(out, e[r.Intn(len(e))])
 	}
 	return out
 }
 
 // Random implements a snowman.ProcessorFunc which returns a random emoji from the emojiList.
-func (e emojiList) Random(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
-	return NewMsg(intent.Msg, fmt.Sprintf(":%v:", e.rand(1)[0])), nil
+func (e emojiList) Random(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	return NewMsg(intent.Msg, fmt.Sprintf(":%v:", e.rand(gobot.Rand(ctx), 1)[0])), nil
 }
 
 // Spin implements a snowman.ProcessorFunc which plays a little gambling game, creating a subset of
 // emoji as a pool and then seeing if you can get 3 of the same emoji. It functions smilar to a slot
 // machine.
-func (e emojiList) Spin(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
-	pool := emojiList(e.rand(4))
+func (e emojiList) Spin(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	r := gobot.Rand(ctx)
+	pool := emojiList(e.rand(r, 4))
 	var results []string
 	for x := 0; x < 3; x++ {
-		results = append(results, pool.rand(1)...)
+		results = append(results, pool.rand(r, 1)...)
 	}
 	// Determine if all 3 emoji are unique by creating a basic set.
 	seen := make(map[string]bool)
@@ -47,12 +51,25 @@ func (e emojiList) Spin(_ context.Context, intent snowman.Intent) (snowman.Msg,
 	} else {
 		msg += " : You lose! Good day, sir!"
 	}
-	return NewMsg(intent.Msg, msg), nil
+	return NewMsg(intent.Msg, msg,
+		rich.Text{Markdown: msg},
+		rich.Buttons{{ActionID: "emoji.spin_again", Label: "Spin again"}},
+	), nil
+}
+
+// SpinAgain implements a snowman.ProcessorFunc which replaces a spin with a new one.
+func (e emojiList) SpinAgain(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	msg, err := e.Spin(ctx, intent)
+	if err != nil {
+		return snowman.Msg{}, err
+	}
+	return Update(msg, intent.Msg), nil
 }
 
 func init() {
-	Reply(`emoji(?: me)?$`, "emoji.random", emoji.Random)
-	Reply(`emoji spin(?: me)?`, "emoji.spin", emoji.Spin)
+	Reply(`emoji(?: me)?$`, "emoji.random", emoji.Random, Usage("emoji me", "show a random emoji"))
+	Reply(`emoji spin(?: me)?`, "emoji.spin", emoji.Spin, Usage("emoji spin", "play the emoji slot machine"))
+	Action("emoji.spin_again", "emoji.spin.again", emoji.SpinAgain)
 }
 
 // emoji is an instance of an emojiList with a common set of emoji used in most Slacks.
@@ -0,0 +1,30 @@
+package modules
+
+import (
+	"testing"
+
+	"github.com/mattikus/gobot/internal/gobot/gobottest"
+)
+
+func TestEmoji(t *testing.T) {
+	conv := startConversation(t)
+	alice := conv.User("alice")
+
+	alice.Tells("emoji me")
+	conv.Expect(`^:[\w+-]+:$`)
+
+	alice.Tells("emoji spin")
+	conv.Expect(`^(?::[\w+-]+:\|){2}:[\w+-]+: : (?:A winner is you!|You lose! Good day, sir!)$`)
+}
+
+func TestEmojiSpinAgain(t *testing.T) {
+	conv := startConversation(t)
+	alice := conv.User("alice")
+
+	alice.Tells("emoji spin")
+	first := conv.Expect(`\S`)
+	alice.Clicks(first, "emoji.spin_again", "")
+	if again := conv.Expect(`A winner is you!|You lose!`); gobottest.Updated(again) != gobottest.TimeStamp(first) {
+		t.Errorf("expected spinning again to update the original spin")
+	}
+}
@@ -0,0 +1,250 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot"
+	"github.com/mattikus/gobot/internal/gobot/attr"
+	"github.com/mattikus/gobot/internal/gobot/rich"
+	"github.com/mattikus/gobot/internal/gobot/store"
+)
+
+const (
+	// enabledNamespace is the store namespace holding whether modules are enabled across the
+	// workspace under "workspace/<module>" and in a channel under "channel/<channel>/<module>".
+	enabledNamespace = "modules"
+	// adminModule is the name of the module which enables and disables the others. It can't be
+	// disabled, or there'd be no way to turn anything back on.
+	adminModule = "modules"
+)
+
+// Settings enables or disables modules across the workspace and in individual channels, limits
+// how often they can be used and configures them. Settings take precedence over anything set with
+// the modules command at the same level, and a module's setting in a channel takes precedence over
+// its setting across the workspace.
+type Settings struct {
+	// Workspace maps module names to whether they're enabled everywhere.
+	Workspace map[string]bool `yaml:"workspace"`
+	// Channels maps channel IDs to module names to whether they're enabled in that channel.
+	Channels map[string]map[string]bool `yaml:"channels"`
+	// RateLimits limits how often modules can be used.
+	RateLimits gobot.RateLimits `yaml:"rate_limits"`
+	// Options maps module names to settings understood by that module.
+	Options map[string]map[string]string `yaml:"options"`
+}
+
+// Validate checks that every module named in the settings exists.
+func (s Settings) Validate() error {
+	known := map[string]bool{}
+	for _, name := range moduleNames() {
+		known[name] = true
+	}
+	check := func(where string, names map[string]bool) error {
+		for name := range names {
+			if !known[name] {
+				return fmt.Errorf("%v: unknown module %q", where, name)
+			}
+		}
+		return nil
+	}
+	if err := check("workspace", s.Workspace); err != nil {
+		return err
+	}
+	for channel, names := range s.Channels {
+		if err := check("channels."+channel, names); err != nil {
+			return err
+		}
+	}
+	for name, opts := range s.Options {
+		if !known[name] {
+			return fmt.Errorf("options: unknown module %q", name)
+		}
+		for key, value := range opts {
+			check, ok := optionChecks[name][key]
+			if !ok {
+				return fmt.Errorf("options.%v: unknown setting %q", name, key)
+			}
+			if err := check(value); err != nil {
+				return fmt.Errorf("options.%v.%v: %w", name, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// optionChecks maps module names to the settings they understand, and how to check their values.
+var optionChecks = map[string]map[string]func(string) error{}
+
+// declareOption declares a setting understood by a module, which can then be given in the Options of
+// Settings.
+func declareOption(module, key string, check func(string) error) {
+	if optionChecks[module] == nil {
+		optionChecks[module] = map[string]func(string) error{}
+	}
+	optionChecks[module][key] = check
+}
+
+// checkDuration checks that a setting is a duration, such as "1m30s".
+func checkDuration(value string) error {
+	_, err := time.ParseDuration(value)
+	return err
+}
+
+// option returns the value of a module's setting, or def if it isn't set.
+func (s Settings) option(module, key, def string) string {
+	if v, ok := s.Options[module][key]; ok {
+		return v
+	}
+	return def
+}
+
+// moduleNames returns the names of every registered module, sorted.
+func moduleNames() []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, ms := range [][]module{replyModules, hearModules, reactModules, actionModules} {
+		for _, m := range ms {
+			if !seen[m.name()] {
+				seen[m.name()] = true
+				out = append(out, m.name())
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// storedEnabled looks up whether a module was enabled or disabled with the modules command.
+func storedEnabled(key string) (enabled, ok bool, err error) {
+	v, err := deps.Store.Get(enabledNamespace, key)
+	switch err {
+	case nil:
+		return string(v) == "on", true, nil
+	case store.ErrNotFound:
+		return false, false, nil
+	default:
+		return false, false, err
+	}
+}
+
+// enabled reports whether a module is enabled in a channel.
+func enabled(name, channel string) (bool, error) {
+	if name == adminModule {
+		return true, nil
+	}
+	if on, ok := deps.Settings.Channels[channel][name]; ok {
+		return on, nil
+	}
+	if on, ok, err := storedEnabled("channel/" + channel + "/" + name); err != nil || ok {
+		return on, err
+	}
+	if on, ok := deps.Settings.Workspace[name]; ok {
+		return on, nil
+	}
+	if on, ok, err := storedEnabled("workspace/" + name); err != nil || ok {
+		return on, err
+	}
+	return true, nil
+}
+
+// gate is processor middleware which stops intents of modules which are disabled in the channel
+// they were triggered in. Only those addressed to the bot get told why nothing happened.
+func gate(next snowman.ProcessorFunc) snowman.ProcessorFunc {
+	return func(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
+		name := strings.SplitN(intent.ID, ".", 2)[0]
+		on, err := enabled(name, channelOf(intent.Msg))
+		if err != nil {
+			return snowman.Msg{}, err
+		}
+		if on {
+			return next(ctx, intent)
+		}
+		if toBot, _ := intent.Msg.Attribs[attr.ToBot].(bool); toBot {
+			return Ephemeral(NewMsg(intent.Msg, fmt.Sprintf("The %v module is disabled here.", name)), intent.Msg), nil
+		}
+		return snowman.Msg{}, nil
+	}
+}
+
+// ModulesToggle implements a snowman.ProcessorFunc which enables or disables a module in a channel,
+// or across the workspace.
+func ModulesToggle(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	name := strings.ToLower(intent.Ctx["module"].(string))
+	on := intent.Ctx["op"] == "enable"
+	if name == adminModule {
+		return Ephemeral(NewMsg(intent.Msg, "The modules module can't be disabled."), intent.Msg), nil
+	}
+	known := false
+	for _, n := range moduleNames() {
+		known = known || n == name
+	}
+	if !known {
+		return Ephemeral(NewMsg(intent.Msg, fmt.Sprintf("I don't know of a module called %q, try `help` to list them.", name)), intent.Msg), nil
+	}
+
+	where, _ := intent.Ctx["where"].(string)
+	key, desc := "workspace/"+name, "everywhere"
+	override, overridden := deps.Settings.Workspace[name]
+	if where != "everywhere" {
+		channel := channelOf(intent.Msg)
+		if id, ok := rich.ParseChannel(where); ok {
+			channel = id
+		}
+		key, desc = "channel/"+channel+"/"+name, "in <#"+channel+">"
+		override, overridden = deps.Settings.Channels[channel][name]
+	}
+
+	value := "off"
+	if on {
+		value = "on"
+	}
+	if err := deps.Store.Put(enabledNamespace, key, []byte(value)); err != nil {
+		return snowman.Msg{}, fmt.Errorf("unable to save setting for module %q: %w", name, err)
+	}
+	body := fmt.Sprintf("The %v module is now %vd %v.", name, intent.Ctx["op"], desc)
+	if overridden && override != on {
+		body = fmt.Sprintf("Saved, but the %v module stays %v %v because of the bot's configuration.", name, onOff(override), desc)
+	}
+	return NewMsg(intent.Msg, body), nil
+}
+
+// ModulesStatus implements a snowman.ProcessorFunc which lists whether each module is enabled in a
+// channel.
+func ModulesStatus(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	channel := channelOf(intent.Msg)
+	if where, ok := intent.Ctx["where"].(string); ok {
+		if id, ok := rich.ParseChannel(where); ok {
+			channel = id
+		}
+	}
+	lines := []string{fmt.Sprintf("*Modules in <#%v>:*", channel)}
+	for _, name := range moduleNames() {
+		on, err := enabled(name, channel)
+		if err != nil {
+			return snowman.Msg{}, err
+		}
+		lines = append(lines, fmt.Sprintf("• %v: %v", name, onOff(on)))
+	}
+	return NewMsg(intent.Msg, strings.Join(lines, "\n")), nil
+}
+
+func onOff(on bool) string {
+	if on {
+		return "enabled"
+	}
+	return "disabled"
+}
+
+func init() {
+	Reply(`^modules (?P<op>enable|disable) (?P<module>\S+)(?: (?:in )?(?P<where><#[^>]+>|everywhere))?$`, "modules.toggle", ModulesToggle,
+		Usage("modules enable|disable <module> [in #channel | everywhere]", "turn a module on or off here, in another channel or everywhere"),
+		RequireRole(RoleAdmin))
+	Reply(`^modules(?: in (?P<where><#[^>]+>))?$`, "modules.status", ModulesStatus,
+		Usage("modules [in #channel]", "list which modules are enabled here or in another channel"))
+}
@@ -0,0 +1,69 @@
+package modules
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/mattikus/gobot/internal/gobot/gobottest"
+	"github.com/mattikus/gobot/internal/gobot/store"
+)
+
+func TestModulesEnabled(t *testing.T) {
+	st := store.NewMemory()
+	conv := startConversationWith(t, Deps{Store: st, Owners: []string{"UALICE"}})
+	alice := conv.User("alice")
+	work := alice.In("CWORK")
+
+	alice.Tells("modules disable cards in <#CWORK|work>")
+	conv.Expect(`^The cards module is now disabled in <#CWORK>\.$`)
+	work.Tells("card me")
+	if msg := conv.Expect(`^The cards module is disabled here\.$`); gobottest.Ephemeral(msg) != alice.User().ID {
+		t.Errorf("expected only alice to be told cards are disabled")
+	}
+	alice.Tells("card me")
+	conv.ExpectIn(gobottest.DefaultChannel, `\S`)
+
+	alice.Tells("modules disable antisocial everywhere")
+	conv.Expect(`^The antisocial module is now disabled everywhere\.$`)
+	work.Says("!maul bob")
+	conv.ExpectNothing()
+	alice.Tells("modules enable antisocial")
+	conv.Expect(`now enabled in <#` + gobottest.DefaultChannel + `>`)
+	alice.Says("!maul bob")
+	conv.Expect(`mauls bob`)
+
+	alice.Tells("modules disable modules")
+	conv.Expect(`can't be disabled`)
+	alice.Tells("modules disable nope")
+	conv.Expect(`I don't know of a module called "nope"`)
+
+	work.Tells("help")
+	if msg := conv.Expect(`Here's what I can do`); regexp.MustCompile(`\*(cards|antisocial)\*`).MatchString(msg.Body) {
+		t.Errorf("expected help to leave out disabled modules, got %q", msg.Body)
+	}
+	work.Tells("modules")
+	conv.Expect(`(?m)^• antisocial: disabled$[\s\S]*^• cards: disabled$[\s\S]*^• karma: enabled$`)
+
+	// Channels are referred to the same way whatever the UI's IDs look like, such as Discord's
+	// numbers or IRC's channel names.
+	alice.Tells("modules disable cards in <#123456789|general>")
+	conv.Expect(`^The cards module is now disabled in <#123456789>\.$`)
+	alice.Tells("modules in <##go-nuts>")
+	conv.Expect(`^\*Modules in <##go-nuts>:\*`)
+	alice.In("#go-nuts").Tells("modules disable karma in <##go-nuts>")
+	conv.Expect(`^The karma module is now disabled in <##go-nuts>\.$`)
+	alice.In("123456789").Tells("modules")
+	conv.Expect(`(?m)^• cards: disabled$`)
+
+	// Settings are persisted, and configuration overrides them.
+	other := startConversationWith(t, Deps{Store: st, Owners: []string{"UALICE"}, Settings: Settings{
+		Channels: map[string]map[string]bool{"CWORK": {"cards": true, "cah": false}},
+	}})
+	work = other.User("alice").In("CWORK")
+	work.Says("!maul bob")
+	other.ExpectNothing()
+	work.Tells("card me")
+	other.Expect(`\S`)
+	work.Tells("modules enable cah")
+	other.Expect(`stays disabled in <#CWORK> because of the bot's configuration`)
+}
@@ -0,0 +1,69 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot"
+)
+
+// lostBecause describes why a pattern lost to the winning one.
+func lostBecause(win, m gobot.Match) string {
+	switch {
+	case win.Reply != m.Reply:
+		return "replies beat patterns which are just overheard"
+	case win.Priority != m.Priority:
+		return fmt.Sprintf("higher priority, %d to %d", win.Priority, m.Priority)
+	case win.Anchors != m.Anchors:
+		return fmt.Sprintf("more anchored, %d to %d", win.Anchors, m.Anchors)
+	case win.Specificity != m.Specificity:
+		return fmt.Sprintf("more specific, %d to %d", win.Specificity, m.Specificity)
+	default:
+		return "registered first"
+	}
+}
+
+// Explain implements a snowman.ProcessorFunc which shows which patterns match a message, as if it
+// was directed to the bot, and why the winning one won.
+func Explain(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	text := intent.Ctx["text"].(string)
+	matches := classifier.Explain(text, true)
+	if len(matches) == 0 {
+		return NewMsg(intent.Msg, fmt.Sprintf("Nothing matches `%v`.", text)), nil
+	}
+
+	var win gobot.Match
+	for _, m := range matches {
+		if m.Won {
+			win = m
+		}
+	}
+	lines := []string{fmt.Sprintf("*Patterns matching `%v`, best first:*", text)}
+	for i, m := range matches {
+		kind := "hear"
+		if m.Reply {
+			kind = "reply"
+		}
+		line := fmt.Sprintf("%d. %v `%v` (%v, priority %d, anchors %d, specificity %d)",
+			i+1, m.IntentID, m.Pattern, kind, m.Priority, m.Anchors, m.Specificity)
+		switch {
+		case m.Won:
+			line += ": *wins*"
+		case m.Multi:
+			line += ": fires too"
+		default:
+			line += fmt.Sprintf(": loses to %v, %v", win.IntentID, lostBecause(win, m))
+		}
+		lines = append(lines, line)
+	}
+	return NewMsg(intent.Msg, strings.Join(lines, "\n")), nil
+}
+
+func init() {
+	Reply(`^explain (?P<text>.+)$`, "explain", Explain,
+		Usage("explain <message>", "show which commands match a message and why one wins"),
+		Priority(1), EphemeralReplies())
+}
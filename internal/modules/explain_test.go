@@ -0,0 +1,19 @@
+package modules
+
+import "testing"
+
+func TestExplain(t *testing.T) {
+	conv := startConversation(t)
+	alice := conv.User("alice")
+
+	alice.Tells("explain karma top")
+	conv.Expect("^\\*Patterns matching `karma top`, best first:\\*\n" +
+		"1\\. karma\\.board .*: \\*wins\\*\n" +
+		"2\\. karma\\.info .*: loses to karma\\.board, registered first$")
+
+	alice.Tells("explain q card me 2")
+	conv.Expect(`1\. cards\.black .*wins.*\n2\. cards\.white .*loses to cards\.black, more specific, 9 to 8$`)
+
+	alice.Tells("explain nothing at all")
+	conv.Expect("^Nothing matches `nothing at all`\\.$")
+}
@@ -0,0 +1,50 @@
+package modules
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/mattikus/gobot/internal/gobot/render"
+	"github.com/mattikus/gobot/internal/gobot/rich"
+)
+
+var update = flag.Bool("update", false, "update the golden files of module output")
+
+// TestGolden checks the rich content modules reply with, rendered as text and HTML, against the files in
+// testdata. Run with -update after changing what a module says.
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		name, command string
+	}{
+		{"baseball", "baseball me --seed 1"},
+		{"cards_black", "q card --seed 3"},
+		{"cards_white", "card me 2 --seed 2"},
+		{"eastwest", "eastwest me --seed 1"},
+		{"emoji_spin", "emoji spin --seed 1"},
+	}
+	conv := startConversation(t)
+	alice := conv.User("alice")
+	for _, tt := range tests {
+		alice.Tells(tt.command)
+		msg := conv.Expect(`.`)
+		blocks := rich.Blocks(msg)
+		got := "# " + tt.command + "\n" + msg.Body + "\n\n# text\n" + render.Text(blocks) + "\n\n# html\n" + render.HTML(blocks) + "\n"
+
+		path := filepath.Join("testdata", tt.name+".golden")
+		if *update {
+			if err := ioutil.WriteFile(path, []byte(got), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("%v: %v, run with -update to create it", tt.name, err)
+		}
+		if got != string(want) {
+			t.Errorf("%v: output changed, run with -update if this is expected.\ngot:\n%v\nwant:\n%v", tt.name, got, string(want))
+		}
+	}
+}
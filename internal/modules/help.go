@@ -0,0 +1,83 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spy16/snowman"
+)
+
+// helpModules returns every registration which has usage documented, grouped by module name.
+func helpModules() map[string][]module {
+	out := map[string][]module{}
+	for _, ms := range [][]module{replyModules, hearModules, reactModules} {
+		for _, m := range ms {
+			if m.usage != "" {
+				out[m.name()] = append(out[m.name()], m)
+			}
+		}
+	}
+	return out
+}
+
+// helpText describes a single module, including any details it provides.
+func helpText(name string, ms []module, details bool) string {
+	lines := []string{fmt.Sprintf("*%v*", name)}
+	for _, m := range ms {
+		line := fmt.Sprintf("• `%v` - %v", m.usage, m.desc)
+		switch {
+		case m.hear:
+			line += " (no need to mention me)"
+		case m.reaction != "":
+			line += " (react to a message)"
+		}
+		if m.role > RoleUser {
+			line += fmt.Sprintf(" (%vs only)", m.role)
+		}
+		lines = append(lines, line)
+		if details && m.details != nil {
+			lines = append(lines, m.details())
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Help implements a snowman.ProcessorFunc which lists what the bot can do, either for every module
+// or for a single one.
+func Help(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	ms := helpModules()
+	if name, ok := intent.Ctx["module"].(string); ok && name != "" {
+		name = strings.ToLower(name)
+		if _, ok := ms[name]; !ok {
+			return NewMsg(intent.Msg, fmt.Sprintf("I don't know of a module called %q, try `help` to list them.", name)), nil
+		}
+		return NewMsg(intent.Msg, helpText(name, ms[name], true)), nil
+	}
+
+	var names []string
+	for name := range ms {
+		// Leave out modules which are disabled where help was asked for.
+		on, err := enabled(name, channelOf(intent.Msg))
+		if err != nil {
+			return snowman.Msg{}, err
+		}
+		if on {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	sections := []string{"Here's what I can do, use `help <module>` for more details. " +
+		"Add `--seed <n>` to the end of any command to repeat a random result."}
+	for _, name := range names {
+		sections = append(sections, helpText(name, ms[name], false))
+	}
+	return NewMsg(intent.Msg, strings.Join(sections, "\n\n")), nil
+}
+
+func init() {
+	// Asking for help about a module mentions it, so help has to outrank the module's own patterns.
+	Reply(`^help(?: (?P<module>\S+))?$`, "help", Help,
+		Usage("help [module]", "list what I can do, or explain a single module"), Priority(1), EphemeralReplies())
+}
@@ -0,0 +1,29 @@
+package modules
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHelp(t *testing.T) {
+	conv := startConversation(t)
+	alice := conv.User("alice")
+
+	alice.Tells("help")
+	msg := conv.Expect(`\*antisocial\*[\s\S]*\*baseball\*[\s\S]*\*cards\*[\s\S]*\*eastwest\*[\s\S]*\*emoji\*`)
+	if strings.Contains(msg.Body, "Triggers:") {
+		t.Errorf("expected module details to be left out of the overview")
+	}
+
+	alice.Tells("help antisocial")
+	conv.Expect(`Triggers: .*\bmaul\b`)
+
+	// Modules whose patterns match their own name don't get to answer help about themselves.
+	for _, name := range []string{"baseball", "emoji", "eastwest", "cards"} {
+		alice.Tells("help " + name)
+		conv.Expect(`^\*` + name + `\*`)
+	}
+
+	alice.Tells("help nope")
+	conv.Expect(`I don't know of a module called "nope"`)
+}
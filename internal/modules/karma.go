@@ -0,0 +1,220 @@
+package modules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot/rich"
+	"github.com/mattikus/gobot/internal/gobot/store"
+)
+
+const (
+	// karmaNamespace is the store namespace holding scores under "score/<thing>" and their reasons
+	// under "reason/<thing>/<time>".
+	karmaNamespace = "karma"
+	// karmaListSize is the default number of entries shown by the leaderboards.
+	karmaListSize = 10
+	// karmaReasons is the number of recent reasons shown when asking about a single thing.
+	karmaReasons = 5
+)
+
+// karmaExp matches a single karma change: a user mention, a parenthesised phrase or a bare word of
+// at least two characters, immediately followed by ++ or -- and then a space, punctuation or the end
+// of the text. Single letters and operators running into more text are left alone, so that C++,
+// i++ and x--y aren't mistaken for karma.
+var karmaExp = regexp.MustCompile(
+	`(?:<@(?P<user>[^\s|>]+)(?:\|[^>]*)?>|\((?P<phrase>[^()]+)\)|(?P<word>\w[\w.:-]*\w))(?P<op>\+\+|--)(?:$|[\s,.!?;)])`)
+
+// karmaReasonExp matches the reason given for a karma change, in the text following it up to the
+// next change or the end of the line.
+var karmaReasonExp = regexp.MustCompile(`^\s*(?:for|because)\s+([^\n]+)`)
+
+type karmaReason struct {
+	By     string    `json:"by"`
+	Delta  int64     `json:"delta"`
+	Reason string    `json:"reason"`
+	At     time.Time `json:"at"`
+}
+
+// karmaThing normalises the name of a thing so that differently cased mentions share a score.
+func karmaThing(s string) string {
+	s = strings.TrimSpace(s)
+	if id, ok := rich.ParseMention(s); ok {
+		return rich.Mention(id)
+	}
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// Karma implements a snowman.ProcessorFunc which adjusts the karma of every thing in the message.
+func Karma(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	var lines []string
+	body := intent.Msg.Body
+	names := karmaExp.SubexpNames()
+	matches := karmaExp.FindAllStringSubmatchIndex(body, -1)
+	for n, m := range matches {
+		groups := map[string]string{}
+		for i, name := range names {
+			if m[2*i] >= 0 {
+				groups[name] = body[m[2*i]:m[2*i+1]]
+			}
+		}
+		// The reason is whatever follows the operator, up to the next change.
+		end := len(body)
+		if n+1 < len(matches) {
+			end = matches[n+1][0]
+		}
+		rest := body[m[2*karmaExp.SubexpIndex("op")+1]:end]
+		if r := karmaReasonExp.FindStringSubmatch(rest); r != nil {
+			groups["reason"] = r[1]
+		}
+
+		var thing string
+		switch {
+		case groups["user"] != "":
+			if groups["user"] == intent.Msg.From.ID {
+				lines = append(lines, "Nice try, but you can't change your own karma.")
+				continue
+			}
+			thing = rich.Mention(groups["user"])
+		case groups["phrase"] != "":
+			thing = karmaThing(groups["phrase"])
+		default:
+			thing = karmaThing(groups["word"])
+		}
+
+		delta := int64(1)
+		if groups["op"] == "--" {
+			delta = -1
+		}
+		score, err := deps.Store.Incr(karmaNamespace, "score/"+thing, delta)
+		if err != nil {
+			return snowman.Msg{}, fmt.Errorf("unable to update karma for %q: %w", thing, err)
+		}
+
+		if reason := strings.TrimSpace(groups["reason"]); reason != "" {
+			r := karmaReason{By: intent.Msg.From.ID, Delta: delta, Reason: reason, At: time.Now()}
+			data, err := json.Marshal(r)
+			if err != nil {
+				return snowman.Msg{}, err
+			}
+			key := fmt.Sprintf("reason/%v/%020d", thing, r.At.UnixNano())
+			if err := deps.Store.Put(karmaNamespace, key, data); err != nil {
+				return snowman.Msg{}, fmt.Errorf("unable to save karma reason for %q: %w", thing, err)
+			}
+		}
+		lines = append(lines, fmt.Sprintf("*%v* has %v karma.", thing, score))
+	}
+	return NewMsg(intent.Msg, strings.Join(lines, "\n")), nil
+}
+
+type karmaScore struct {
+	thing string
+	score int64
+}
+
+// karmaScores returns every score, highest first.
+func karmaScores() ([]karmaScore, error) {
+	kvs, err := deps.Store.List(karmaNamespace, "score/")
+	if err != nil {
+		return nil, err
+	}
+	var out []karmaScore
+	for _, kv := range kvs {
+		n, err := store.Int(kv.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid karma score for %q: %w", kv.Key, err)
+		}
+		out = append(out, karmaScore{strings.TrimPrefix(kv.Key, "score/"), n})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].score > out[j].score })
+	return out, nil
+}
+
+// KarmaBoard implements a snowman.ProcessorFunc which shows the highest or lowest scores.
+func KarmaBoard(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	count := karmaListSize
+	if n, ok := intent.Ctx["count"].(string); ok && n != "" {
+		var err error
+		if count, err = strconv.Atoi(n); err != nil {
+			return snowman.Msg{}, err
+		}
+	}
+	scores, err := karmaScores()
+	if err != nil {
+		return snowman.Msg{}, err
+	}
+	if len(scores) == 0 {
+		return NewMsg(intent.Msg, "Nobody has any karma yet."), nil
+	}
+
+	title := "Top karma"
+	if intent.Ctx["board"] == "bottom" {
+		title = "Bottom karma"
+		for i, j := 0, len(scores)-1; i < j; i, j = i+1, j-1 {
+			scores[i], scores[j] = scores[j], scores[i]
+		}
+	}
+	if count < len(scores) {
+		scores = scores[:count]
+	}
+	lines := []string{fmt.Sprintf("*%v:*", title)}
+	for idx, s := range scores {
+		lines = append(lines, fmt.Sprintf("%v. %v: %v", idx+1, s.thing, s.score))
+	}
+	return NewMsg(intent.Msg, strings.Join(lines, "\n")), nil
+}
+
+// KarmaInfo implements a snowman.ProcessorFunc which shows the score of a single thing along with
+// the most recent reasons it was given.
+func KarmaInfo(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	thing := karmaThing(intent.Ctx["thing"].(string))
+	var score int64
+	v, err := deps.Store.Get(karmaNamespace, "score/"+thing)
+	switch err {
+	case nil:
+		if score, err = store.Int(v); err != nil {
+			return snowman.Msg{}, fmt.Errorf("invalid karma score for %q: %w", thing, err)
+		}
+	case store.ErrNotFound:
+	default:
+		return snowman.Msg{}, err
+	}
+
+	lines := []string{fmt.Sprintf("*%v* has %v karma.", thing, score)}
+	kvs, err := deps.Store.List(karmaNamespace, "reason/"+thing+"/")
+	if err != nil {
+		return snowman.Msg{}, err
+	}
+	if len(kvs) > karmaReasons {
+		kvs = kvs[len(kvs)-karmaReasons:]
+	}
+	for i := len(kvs) - 1; i >= 0; i-- {
+		var r karmaReason
+		if err := json.Unmarshal(kvs[i].Value, &r); err != nil {
+			return snowman.Msg{}, fmt.Errorf("invalid karma reason %q: %w", kvs[i].Key, err)
+		}
+		op := "++"
+		if r.Delta < 0 {
+			op = "--"
+		}
+		lines = append(lines, fmt.Sprintf(">%v %v from <@%v>", op, r.Reason, r.By))
+	}
+	return NewMsg(intent.Msg, strings.Join(lines, "\n")), nil
+}
+
+func init() {
+	Reply(`^karma(?: (?P<board>top|bottom)(?: (?P<count>\d+))?)?$`, "karma.board", KarmaBoard,
+		Usage("karma top|bottom [count]", "show the highest or lowest karma"))
+	Reply(`^karma (?P<thing>.+)$`, "karma.info", KarmaInfo,
+		Usage("karma <thing>", "show the karma of something and why it was given"))
+	Hear(karmaExp.String(), "karma", Karma,
+		Usage("<thing>++ [for <reason>]", "give or take (with --) karma from a word, @user or (multiple words)"))
+}
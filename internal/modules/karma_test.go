@@ -0,0 +1,41 @@
+package modules
+
+import "testing"
+
+func TestKarma(t *testing.T) {
+	conv := startConversation(t)
+	alice, bob := conv.User("alice"), conv.User("bob")
+
+	bob.Says(alice.Mention() + "++ for writing tests")
+	conv.Expect(`^\*<@UALICE>\* has 1 karma\.$`)
+
+	alice.Says(alice.Mention() + "++")
+	conv.Expect(`^Nice try`)
+
+	alice.Says("(Unit Tests)++ and flaky-- in C++, g++ and x--y")
+	conv.Expect(`^\*unit tests\* has 1 karma\.\n\*flaky\* has -1 karma\.$`)
+
+	// A reason only runs up to the next change, which gets its own.
+	alice.Says("docs++ for tests " + bob.Mention() + "++ because reviews\nflaky-- ok")
+	conv.Expect(`^\*docs\* has 1 karma\.\n\*<@UBOB>\* has 1 karma\.\n\*flaky\* has -2 karma\.$`)
+	bob.Tells("karma docs")
+	conv.Expect(`^\*docs\* has 1 karma\.\n>\+\+ tests from <@UALICE>$`)
+	bob.Tells("karma " + bob.Mention())
+	conv.Expect(`>\+\+ reviews from <@UALICE>$`)
+
+	bob.Tells("karma " + alice.Mention())
+	conv.Expect(`^\*<@UALICE>\* has 1 karma\.\n>\+\+ writing tests from <@UBOB>$`)
+
+	bob.Tells("karma top 2")
+	conv.Expect(`^\*Top karma:\*\n1\. <@UALICE>: 1\n2\. <@UBOB>: 1$`)
+
+	bob.Tells("karma bottom")
+	conv.Expect(`^\*Bottom karma:\*\n1\. flaky: -2\n`)
+
+	// Users are mentioned by whatever ID their UI gives them, such as the numbers Discord uses.
+	bob.Says("<@200|carol>++")
+	conv.Expect(`^\*<@200>\* has 1 karma\.$`)
+
+	bob.Says("nothing to see here -- honest")
+	conv.ExpectNothing()
+}
@@ -2,9 +2,13 @@
 package modules
 
 import (
+	"context"
+	"strings"
+
 	"github.com/mattikus/gobot/internal/gobot"
-	"github.com/slack-go/slack"
-	"github.com/slack-go/slack/slackevents"
+	"github.com/mattikus/gobot/internal/gobot/attr"
+	"github.com/mattikus/gobot/internal/gobot/rich"
+	"github.com/mattikus/gobot/internal/gobot/store"
 	"github.com/spy16/snowman"
 )
 
@@ -12,51 +16,300 @@ type module struct {
 	id    string
 	regex string
 	fun   snowman.ProcessorFunc
+
+	hear      bool
+	actionID  string
+	reaction  string
+	removed   bool
+	ephemeral bool
+	role      Role
+	usage     string
+	desc      string
+	details   func() string
+	// match configures how the pattern competes with others matching the same message.
+	match []gobot.PatternOption
+}
+
+// name returns the name of the module the registration belongs to, which is the prefix of its
+// intent ID.
+func (m module) name() string {
+	return strings.SplitN(m.id, ".", 2)[0]
+}
+
+// Option configures a module registration.
+type Option func(*module)
+
+// Usage documents how to trigger a registration and what it does for the help command.
+func Usage(usage, desc string) Option {
+	return func(m *module) {
+		m.usage = usage
+		m.desc = desc
+	}
+}
+
+// Details adds extra help which is only shown when asking for help with a specific module. It's
+// called every time help is shown so it can describe things which change at runtime.
+func Details(fun func() string) Option {
+	return func(m *module) {
+		m.details = fun
+	}
+}
+
+// Removed makes a reaction registration respond to the reaction being removed from a message,
+// rather than added.
+func Removed() Option {
+	return func(m *module) {
+		m.removed = true
+	}
+}
+
+// EphemeralReplies makes every reply only visible to the user who triggered it, as with Ephemeral.
+func EphemeralReplies() Option {
+	return func(m *module) {
+		m.ephemeral = true
+	}
+}
+
+// Priority ranks the pattern of a hear or reply registration above those with a lower priority when
+// several match a message. Registrations have a priority of 0 unless given another.
+func Priority(n int) Option {
+	return func(m *module) {
+		m.match = append(m.match, gobot.Priority(n))
+	}
+}
+
+// MultiMatch makes a hear registration fire whenever it matches a message, alongside whichever
+// registration wins, rather than competing with the others.
+func MultiMatch() Option {
+	return func(m *module) {
+		m.match = append(m.match, gobot.MultiMatch())
+	}
+}
+
+func newModule(re, id string, fun snowman.ProcessorFunc, opts []Option) module {
+	m := module{id: id, regex: re, fun: fun}
+	for _, opt := range opts {
+		opt(&m)
+	}
+	return m
+}
+
+// processor returns the function which handles the registration's intent, applying any options
+// which affect its replies.
+func (m module) processor() snowman.ProcessorFunc {
+	if !m.ephemeral {
+		return m.fun
+	}
+	return func(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
+		msg, err := m.fun(ctx, intent)
+		if msg.Attribs != nil {
+			msg = Ephemeral(msg, intent.Msg)
+		}
+		return msg, err
+	}
 }
 
 var replyModules []module
 
-func Reply(re, id string, fun snowman.ProcessorFunc) {
-	replyModules = append(replyModules, module{id, re, fun})
+// Reply registers a module which responds to messages directed at the bot.
+func Reply(re, id string, fun snowman.ProcessorFunc, opts ...Option) {
+	replyModules = append(replyModules, newModule(re, id, fun, opts))
+}
+
+var actionModules []module
+
+// Action registers a module which responds to interactions with elements, such as buttons, which
+// have the given action ID. The element's value is available as "value" in the intent context.
+func Action(actionID, id string, fun snowman.ProcessorFunc, opts ...Option) {
+	m := newModule("", id, fun, opts)
+	m.actionID = actionID
+	actionModules = append(actionModules, m)
+}
+
+var reactModules []module
+
+// React registers a module which responds to an emoji reaction, named without colons, being added
+// to a message. The author and timestamp of the message reacted to are available as "user" and "ts"
+// in the intent context, and replies to the reaction are posted alongside that message.
+func React(reaction, id string, fun snowman.ProcessorFunc, opts ...Option) {
+	m := newModule("", id, fun, opts)
+	m.reaction = reaction
+	reactModules = append(reactModules, m)
 }
 
 var hearModules []module
 
-func Hear(re, id string, fun snowman.ProcessorFunc) {
-	hearModules = append(hearModules, module{id, re, fun})
+// Hear registers a module which responds to any message the bot sees.
+func Hear(re, id string, fun snowman.ProcessorFunc, opts ...Option) {
+	m := newModule(re, id, fun, opts)
+	m.hear = true
+	hearModules = append(hearModules, m)
+}
+
+// Deps holds the dependencies shared by every module.
+type Deps struct {
+	// Store persists module state. Each module keeps its keys in a namespace named after itself.
+	Store store.Store
+	// Owners are the user IDs of the bot's owners, who can give other users roles.
+	Owners []string
+	// Settings enables or disables modules, overriding the modules command, and limits how often
+	// they can be used.
+	Settings Settings
+}
+
+// deps holds the dependencies handed to Register.
+var deps Deps
+
+// classifier is the classifier handed to Register, kept for modules which change their patterns at
+// runtime.
+var classifier *gobot.Classifier
+
+// registerHooks are run at the end of Register, once every module has been registered.
+var registerHooks []func() error
+
+// onRegister adds a function to run at the end of Register, for modules which need to set
+// themselves up using their dependencies.
+func onRegister(fun func() error) {
+	registerHooks = append(registerHooks, fun)
 }
 
 // NewMsg takes a message to reply to and creates a new message with the correct room already set to
-// reply.
-func NewMsg(replyTo snowman.Msg, body string, blocks ...slack.Block) snowman.Msg {
-	var channel string
-	if omsg, ok := replyTo.Attribs["slack_msg"].(*slackevents.MessageEvent); ok {
-		channel = omsg.Channel
+// reply. Replies to a message in a thread are posted in the same thread, and replies to slash
+// commands are sent as the command's response. Any rich content is shown instead of the body by UIs
+// which can display it.
+func NewMsg(replyTo snowman.Msg, body string, content ...rich.Block) snowman.Msg {
+	thread, _ := replyTo.Attribs[attr.Thread].(string)
+	msg := snowman.Msg{
+		Body: body,
+		Attribs: map[string]interface{}{
+			attr.Channel: channelOf(replyTo),
+			attr.Thread:  thread,
+			rich.Key:     content,
+		},
+	}
+	if response, ok := replyTo.Attribs[attr.Response]; ok {
+		msg.Attribs[attr.Response] = response
 	}
+	return msg
+}
+
+// StartThread makes msg start a new thread under the message it replies to, rather than being
+// posted alongside it. Messages which are already in a thread stay in that thread.
+func StartThread(msg, replyTo snowman.Msg) snowman.Msg {
+	if thread, _ := msg.Attribs[attr.Thread].(string); thread != "" {
+		return msg
+	}
+	if id, _ := replyTo.Attribs[attr.ID].(string); id != "" {
+		msg.Attribs[attr.Thread] = id
+	}
+	return msg
+}
+
+// Update makes msg replace the message it replies to instead of being posted as a new message. It's
+// meant for replies to interactions, such as a button being clicked, where the message replied to
+// is the one the button belongs to.
+func Update(msg, replyTo snowman.Msg) snowman.Msg {
+	if id, _ := replyTo.Attribs[attr.ID].(string); id != "" {
+		msg.Attribs[attr.Update] = id
+	}
+	return msg
+}
+
+// Ephemeral makes msg only visible to the user who sent the message it replies to, so that replies
+// of no interest to anyone else, such as errors, don't clutter the channel. Replies in a DM are
+// private anyway, so are left as they are.
+func Ephemeral(msg, replyTo snowman.Msg) snowman.Msg {
+	if !isDM(replyTo) {
+		msg.Attribs[attr.Ephemeral] = replyTo.From.ID
+	}
+	return msg
+}
+
+// Broadcast makes a reply in a thread also show up in the channel the thread belongs to.
+func Broadcast(msg snowman.Msg) snowman.Msg {
+	msg.Attribs[attr.Broadcast] = true
+	return msg
+}
+
+// NewDM creates a new message which is sent privately to the given user, in their DM with the bot.
+func NewDM(to snowman.User, body string, content ...rich.Block) snowman.Msg {
 	return snowman.Msg{
 		Body: body,
 		Attribs: map[string]interface{}{
-			"slack_channel": channel,
-			"slack_blocks":  blocks,
+			attr.DMUser: to.ID,
+			rich.Key:    content,
 		},
 	}
 }
 
+// channelOf returns the channel a message was sent in.
+func channelOf(msg snowman.Msg) string {
+	channel, _ := msg.Attribs[attr.Channel].(string)
+	return channel
+}
+
+// isDM reports whether a message was sent directly to the bot.
+func isDM(msg snowman.Msg) bool {
+	dm, _ := msg.Attribs[attr.DM].(bool)
+	return dm
+}
+
 // Register injects all of the functionality defined within modules.
-func Register(c *gobot.Classifier, pp *gobot.Processor) error {
+func Register(c *gobot.Classifier, pp *gobot.Processor, d Deps) error {
+	if d.Store == nil {
+		d.Store = store.NewMemory()
+	}
+	deps = d
+	classifier = c
+	registered := map[string]bool{}
+	listening := map[string]bool{}
+	required := map[string]Role{}
+	for _, ms := range [][]module{hearModules, replyModules, reactModules, actionModules} {
+		for _, m := range ms {
+			registered[m.id] = true
+			listening[m.id] = m.hear || m.reaction != ""
+			required[m.id] = m.role
+		}
+	}
+	pp.Use(gate, authorize(required), rateLimit(gobot.NewRateLimiter(d.Settings.RateLimits), registered, listening))
 	for _, i := range hearModules {
-		if err := c.Hear(i.regex, i.id); err != nil {
+		if err := c.Hear(i.regex, i.id, i.match...); err != nil {
 			return err
 		}
-		if err := pp.Register(i.id, i.fun); err != nil {
+		if err := pp.Register(i.id, i.processor()); err != nil {
 			return err
 		}
 	}
 	for _, i := range replyModules {
-		if err := c.Reply(i.regex, i.id); err != nil {
+		if err := c.Reply(i.regex, i.id, i.match...); err != nil {
+			return err
+		}
+		if err := pp.Register(i.id, i.processor()); err != nil {
+			return err
+		}
+	}
+	for _, i := range reactModules {
+		register := c.Reaction
+		if i.removed {
+			register = c.ReactionRemoved
+		}
+		if err := register(i.reaction, i.id); err != nil {
+			return err
+		}
+		if err := pp.Register(i.id, i.processor()); err != nil {
+			return err
+		}
+	}
+	for _, i := range actionModules {
+		if err := c.Action(i.actionID, i.id); err != nil {
 			return err
 		}
-		if err := pp.Register(i.id, i.fun); err != nil {
+		if err := pp.Register(i.id, i.processor()); err != nil {
+			return err
+		}
+	}
+	for _, hook := range registerHooks {
+		if err := hook(); err != nil {
 			return err
 		}
 	}
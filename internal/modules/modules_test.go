@@ -0,0 +1,108 @@
+package modules
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot"
+	"github.com/mattikus/gobot/internal/gobot/gobottest"
+	"github.com/mattikus/gobot/internal/gobot/store"
+)
+
+// startConversation runs every module against a fake UI with a fresh store.
+func startConversation(t *testing.T) *gobottest.Conversation {
+	return startConversationWith(t, Deps{Store: store.NewMemory()})
+}
+
+// startConversationWith runs every module against a fake UI with the given dependencies.
+func startConversationWith(t *testing.T, d Deps) *gobottest.Conversation {
+	return gobottest.Start(t, func(c *gobot.Classifier, pp *gobot.Processor, ui snowman.UI) error {
+		return Register(c, pp, d)
+	})
+}
+
+func TestRegister(t *testing.T) {
+	for _, m := range append(append(replyModules, hearModules...), reactModules...) {
+		if m.usage == "" {
+			t.Errorf("module %q has no usage", m.id)
+		}
+	}
+}
+
+func TestSeed(t *testing.T) {
+	conv := startConversation(t)
+	alice := conv.User("alice")
+
+	alice.Tells("emoji spin --seed 42")
+	first := conv.Expect(`\S`)
+	alice.Tells("card me 3")
+	conv.Expect(`\S`)
+	alice.Tells("emoji spin --seed 42")
+	if again := conv.Expect(`\S`); again.Body != first.Body {
+		t.Errorf("expected the same seed to give the same spin, got %q and %q", first.Body, again.Body)
+	}
+
+	// A second bot with the same seed makes the same choices.
+	other := startConversation(t)
+	alice.Tells("card me 3")
+	got := conv.Expect(`\S`)
+	other.User("alice").Tells("emoji spin --seed 42")
+	other.Expect(`^` + regexp.QuoteMeta(first.Body) + `$`)
+	other.User("alice").Tells("card me 3")
+	other.Expect(`\S`)
+	other.User("alice").Tells("card me 3")
+	if again := other.Expect(`\S`); again.Body != got.Body {
+		t.Errorf("expected bots with the same seed to deal the same cards, got %q and %q", got.Body, again.Body)
+	}
+}
+
+func TestThreads(t *testing.T) {
+	conv := startConversation(t)
+	alice := conv.User("alice")
+
+	alice.Says("!maul bob")
+	if msg := conv.Expect(`mauls`); gobottest.Thread(msg) != "" {
+		t.Errorf("expected a reply to a channel message to stay in the channel, got thread %q", gobottest.Thread(msg))
+	}
+
+	alice.InThread("1.000001").Says("!maul bob")
+	if msg := conv.Expect(`mauls`); gobottest.Thread(msg) != "1.000001" {
+		t.Errorf("expected a reply in thread 1.000001, got %q", gobottest.Thread(msg))
+	}
+
+}
+
+func TestEphemeral(t *testing.T) {
+	conv := startConversation(t)
+	alice := conv.User("alice")
+
+	alice.Tells("help")
+	if msg := conv.Expect(`Here's what I can do`); gobottest.Ephemeral(msg) != alice.User().ID {
+		t.Errorf("expected help to only be shown to alice, got %q", gobottest.Ephemeral(msg))
+	}
+	alice.DMs("help")
+	if msg := conv.Expect(`Here's what I can do`); gobottest.Ephemeral(msg) != "" {
+		t.Errorf("expected help in a DM to be posted normally")
+	}
+	alice.Tells("cah hand")
+	if msg := conv.Expect(`You're not in a game`); gobottest.Ephemeral(msg) != alice.User().ID {
+		t.Errorf("expected errors to only be shown to alice")
+	}
+}
+
+func TestSlashCommands(t *testing.T) {
+	conv := startConversation(t)
+	alice := conv.User("alice")
+
+	alice.Slash("help cards")
+	if msg := conv.Expect(`card me`); gobottest.Ephemeral(msg) != alice.User().ID {
+		t.Errorf("expected help to only be shown to the user who asked for it")
+	}
+
+	alice.Slash("card me 2")
+	if msg := conv.Expect(`\S`); gobottest.Ephemeral(msg) != "" {
+		t.Errorf("expected cards to be dealt in the channel")
+	}
+}
@@ -0,0 +1,36 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot"
+)
+
+// rateLimit is processor middleware which stops users triggering the intents of modules more often
+// than limiter allows, telling them how long to wait instead. Intents which listen in on
+// conversations, rather than being asked for, are dropped without a word. Intents not registered
+// by a module are left alone.
+func rateLimit(limiter *gobot.RateLimiter, registered, listening map[string]bool) gobot.Middleware {
+	return func(next snowman.ProcessorFunc) snowman.ProcessorFunc {
+		return func(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
+			if !registered[intent.ID] {
+				return next(ctx, intent)
+			}
+			wait, ok := limiter.Allow(intent.Msg.From.ID, channelOf(intent.Msg), intent.ID)
+			if ok {
+				return next(ctx, intent)
+			}
+			if listening[intent.ID] {
+				return snowman.Msg{}, nil
+			}
+			// Round up, so that trying again when told to always works.
+			wait = (wait + time.Second - 1).Truncate(time.Second)
+			body := fmt.Sprintf("Slow down! Try that again in %v.", wait)
+			return Ephemeral(NewMsg(intent.Msg, body), intent.Msg), nil
+		}
+	}
+}
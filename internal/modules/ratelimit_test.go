@@ -0,0 +1,45 @@
+package modules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattikus/gobot/internal/gobot"
+	"github.com/mattikus/gobot/internal/gobot/gobottest"
+	"github.com/mattikus/gobot/internal/gobot/store"
+)
+
+func TestRateLimit(t *testing.T) {
+	conv := startConversationWith(t, Deps{Store: store.NewMemory(), Settings: Settings{
+		RateLimits: gobot.RateLimits{Intents: map[string]gobot.Limit{
+			"emoji.spin": {Burst: 1, Every: time.Hour},
+		}},
+	}})
+	alice, bob := conv.User("alice"), conv.User("bob")
+
+	alice.Tells("emoji spin")
+	conv.Expect(`A winner is you!|You lose!`)
+	alice.Tells("emoji spin")
+	if msg := conv.Expect(`^Slow down! Try that again in 1h0m0s\.$`); gobottest.Ephemeral(msg) != alice.User().ID {
+		t.Errorf("expected only alice to be told to slow down")
+	}
+	bob.Tells("emoji spin")
+	conv.Expect(`A winner is you!|You lose!`)
+	alice.Tells("emoji me")
+	conv.Expect(`^:\S+:$`)
+}
+
+func TestRateLimitListening(t *testing.T) {
+	conv := startConversationWith(t, Deps{Store: store.NewMemory(), Settings: Settings{
+		RateLimits: gobot.RateLimits{User: gobot.Limit{Burst: 1, Every: time.Hour}},
+	}})
+	alice := conv.User("alice")
+
+	alice.Says("bob++")
+	conv.Expect(`bob`)
+	// Karma listens in rather than being asked for, so going over the limit isn't worth a reply.
+	alice.Says("bob++")
+	conv.ExpectNothing()
+	alice.Tells("emoji me")
+	conv.Expect(`^Slow down!`)
+}
@@ -0,0 +1,184 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot"
+	"github.com/mattikus/gobot/internal/gobot/attr"
+	"github.com/mattikus/gobot/internal/gobot/rich"
+	"github.com/mattikus/gobot/internal/gobot/store"
+)
+
+// rolesNamespace is the store namespace holding the role of each user under their user ID.
+const rolesNamespace = "roles"
+
+// Role is what a user is allowed to do with the bot. Each role can do everything the roles below it
+// can.
+type Role int
+
+const (
+	// RoleUser is the role of everyone who hasn't been given another.
+	RoleUser Role = iota
+	// RoleAdmin can use privileged commands, such as disabling modules.
+	RoleAdmin
+	// RoleOwner can also give other users roles.
+	RoleOwner
+)
+
+var roleNames = map[Role]string{RoleUser: "user", RoleAdmin: "admin", RoleOwner: "owner"}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
+
+// parseRole returns the role with the given name.
+func parseRole(name string) (Role, bool) {
+	for r, n := range roleNames {
+		if n == name {
+			return r, true
+		}
+	}
+	return RoleUser, false
+}
+
+// RequireRole restricts a registration to users with at least the given role. Everyone else is told
+// they aren't allowed to use it.
+func RequireRole(r Role) Option {
+	return func(m *module) {
+		m.role = r
+	}
+}
+
+// roleOf returns the role of a user. Owners named in Deps can't be given a lesser role.
+func roleOf(userID string) (Role, error) {
+	for _, id := range deps.Owners {
+		if id == userID {
+			return RoleOwner, nil
+		}
+	}
+	v, err := deps.Store.Get(rolesNamespace, userID)
+	switch err {
+	case nil:
+		r, ok := parseRole(string(v))
+		if !ok {
+			return RoleUser, fmt.Errorf("invalid role %q stored for %q", v, userID)
+		}
+		return r, nil
+	case store.ErrNotFound:
+		return RoleUser, nil
+	default:
+		return RoleUser, err
+	}
+}
+
+// authorize is processor middleware which rejects intents from users without the role required by
+// the registration. Users the UI couldn't verify are treated as having no role.
+func authorize(required map[string]Role) gobot.Middleware {
+	return func(next snowman.ProcessorFunc) snowman.ProcessorFunc {
+		return func(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
+			need := required[intent.ID]
+			if need == RoleUser {
+				return next(ctx, intent)
+			}
+			r, err := roleOf(intent.Msg.From.ID)
+			if err != nil {
+				return snowman.Msg{}, err
+			}
+			if unverified, _ := intent.Msg.Attribs[attr.Unverified].(bool); unverified {
+				r = RoleUser
+			}
+			if r >= need {
+				return next(ctx, intent)
+			}
+			body := fmt.Sprintf("Sorry, only %vs can do that.", need)
+			return Ephemeral(NewMsg(intent.Msg, body), intent.Msg), nil
+		}
+	}
+}
+
+// RolesSet implements a snowman.ProcessorFunc which gives a user a role.
+func RolesSet(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	user, ok := rich.ParseMention(intent.Ctx["user"].(string))
+	if !ok {
+		return Ephemeral(NewMsg(intent.Msg, "Mention the user to give a role to, as in `roles @someone admin`."), intent.Msg), nil
+	}
+	r, _ := parseRole(strings.ToLower(intent.Ctx["role"].(string)))
+	if current, err := roleOf(user); err != nil {
+		return snowman.Msg{}, err
+	} else if current == RoleOwner && r < RoleOwner {
+		for _, id := range deps.Owners {
+			if id == user {
+				return Ephemeral(NewMsg(intent.Msg, rich.Mention(user)+" is an owner in the bot's configuration, so can't be given another role."), intent.Msg), nil
+			}
+		}
+	}
+	var err error
+	if r == RoleUser {
+		err = deps.Store.Delete(rolesNamespace, user)
+	} else {
+		err = deps.Store.Put(rolesNamespace, user, []byte(r.String()))
+	}
+	if err != nil {
+		return snowman.Msg{}, fmt.Errorf("unable to save role for %q: %w", user, err)
+	}
+	return NewMsg(intent.Msg, fmt.Sprintf("%v is now a%v %v.", rich.Mention(user), article(r), r)), nil
+}
+
+// RolesList implements a snowman.ProcessorFunc which lists every user with a role.
+func RolesList(_ context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	roles := map[string]Role{}
+	kvs, err := deps.Store.List(rolesNamespace, "")
+	if err != nil {
+		return snowman.Msg{}, err
+	}
+	for _, kv := range kvs {
+		if r, ok := parseRole(string(kv.Value)); ok {
+			roles[kv.Key] = r
+		}
+	}
+	for _, id := range deps.Owners {
+		roles[id] = RoleOwner
+	}
+	if len(roles) == 0 {
+		return NewMsg(intent.Msg, "Nobody has a role yet."), nil
+	}
+
+	var users []string
+	for id := range roles {
+		users = append(users, id)
+	}
+	// Most privileged first, then by ID so the list is stable.
+	sort.Slice(users, func(i, j int) bool {
+		if roles[users[i]] != roles[users[j]] {
+			return roles[users[i]] > roles[users[j]]
+		}
+		return users[i] < users[j]
+	})
+	lines := []string{"*Roles:*"}
+	for _, id := range users {
+		lines = append(lines, fmt.Sprintf("• %v: %v", rich.Mention(id), roles[id]))
+	}
+	return NewMsg(intent.Msg, strings.Join(lines, "\n")), nil
+}
+
+func article(r Role) string {
+	if r == RoleAdmin || r == RoleOwner {
+		return "n"
+	}
+	return ""
+}
+
+func init() {
+	Reply(`^roles (?P<user>\S+) (?P<role>(?i)owner|admin|user)$`, "roles.set", RolesSet,
+		Usage("roles @user owner|admin|user", "give someone a role"), RequireRole(RoleOwner))
+	Reply(`^roles$`, "roles.list", RolesList,
+		Usage("roles", "list everyone who has a role"), RequireRole(RoleAdmin))
+}
@@ -0,0 +1,73 @@
+package modules
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mattikus/gobot/internal/gobot/attr"
+	"github.com/mattikus/gobot/internal/gobot/gobottest"
+	"github.com/mattikus/gobot/internal/gobot/store"
+)
+
+func TestRoles(t *testing.T) {
+	st := store.NewMemory()
+	conv := startConversationWith(t, Deps{Store: st, Owners: []string{"UALICE"}})
+	alice, bob, carol := conv.User("alice"), conv.User("bob"), conv.User("carol")
+
+	bob.Tells("modules disable cards")
+	if msg := conv.Expect(`^Sorry, only admins can do that\.$`); gobottest.Ephemeral(msg) != bob.User().ID {
+		t.Errorf("expected only bob to be told he isn't allowed")
+	}
+	bob.Tells("roles " + carol.Mention() + " admin")
+	conv.Expect(`^Sorry, only owners can do that\.$`)
+
+	alice.Tells("roles " + bob.Mention() + " admin")
+	conv.Expect(`^<@UBOB> is now an admin\.$`)
+	bob.Tells("modules disable cards")
+	conv.Expect(`now disabled`)
+	bob.Tells("roles " + carol.Mention() + " admin")
+	conv.Expect(`^Sorry, only owners can do that\.$`)
+
+	alice.Tells("roles " + carol.Mention() + " owner")
+	conv.Expect(`^<@UCAROL> is now an owner\.$`)
+	carol.Tells("roles " + alice.Mention() + " user")
+	conv.Expect(`owner in the bot's configuration`)
+	carol.Tells("roles nobody admin")
+	conv.Expect(`Mention the user`)
+
+	// Users are mentioned the same way whatever the UI's IDs look like, such as Discord's numbers or
+	// IRC's accounts.
+	carol.Tells("roles <@123456789|dave> admin")
+	conv.Expect(`^<@123456789> is now an admin\.$`)
+	carol.Tells("roles <@erin> admin")
+	conv.Expect(`^<@erin> is now an admin\.$`)
+
+	bob.Tells("roles")
+	conv.Expect(`^\*Roles:\*\n• <@UALICE>: owner\n• <@UCAROL>: owner\n• <@123456789>: admin\n• <@UBOB>: admin\n• <@erin>: admin$`)
+
+	// Roles are persisted.
+	other := startConversationWith(t, Deps{Store: st})
+	other.User("carol").Tells("roles " + bob.Mention() + " user")
+	other.Expect(`^<@UBOB> is now a user\.$`)
+	other.User("bob").Tells("roles")
+	other.Expect(`^Sorry, only admins can do that\.$`)
+
+	alice.Tells("help modules")
+	conv.Expect(`modules enable\|disable .*\(admins only\)`)
+}
+
+func TestRolesUnverified(t *testing.T) {
+	conv := startConversationWith(t, Deps{Store: store.NewMemory(), Owners: []string{"UALICE"}})
+	alice := conv.User("alice")
+
+	// Whoever can't prove they're alice doesn't get her role, even with her ID.
+	msg := gobottest.NewMsg(alice.User(), gobottest.DefaultChannel, "", "modules disable cards", true)
+	msg.Attribs[attr.Unverified] = true
+	if err := conv.UI().Inject(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	conv.Expect(`^Sorry, only admins can do that\.$`)
+
+	alice.Tells("modules disable cards")
+	conv.Expect(`now disabled`)
+}
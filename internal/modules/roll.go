@@ -0,0 +1,55 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spy16/snowman"
+
+	"github.com/mattikus/gobot/internal/gobot"
+)
+
+const (
+	maxDice  = 100
+	maxSides = 1000
+)
+
+// Roll implements a snowman.ProcessorFunc which rolls dice. The seed of the roll is shown so that
+// anyone can check the result by rolling again with `--seed`.
+func Roll(ctx context.Context, intent snowman.Intent) (snowman.Msg, error) {
+	count, sides := 1, 6
+	if s, ok := intent.Ctx["count"].(string); ok && s != "" {
+		count, _ = strconv.Atoi(s)
+	}
+	if s, ok := intent.Ctx["sides"].(string); ok && s != "" {
+		sides, _ = strconv.Atoi(s)
+	}
+	if count < 1 || count > maxDice || sides < 2 || sides > maxSides {
+		return NewMsg(intent.Msg, fmt.Sprintf("I can only roll between 1 and %v dice with 2 to %v sides.", maxDice, maxSides)), nil
+	}
+
+	r := gobot.Rand(ctx)
+	var rolls []string
+	total := 0
+	for i := 0; i < count; i++ {
+		n := r.Intn(sides) + 1
+		total += n
+		rolls = append(rolls, strconv.Itoa(n))
+	}
+	dice := fmt.Sprintf("%vd%v", count, sides)
+	body := fmt.Sprintf(":game_die: %v: %v", dice, rolls[0])
+	if count > 1 {
+		body = fmt.Sprintf(":game_die: %v: %v = *%v*", dice, strings.Join(rolls, " + "), total)
+	}
+	if seed, ok := gobot.Seed(ctx); ok {
+		body += fmt.Sprintf(" (check with `roll %v --seed %v`)", dice, seed)
+	}
+	return NewMsg(intent.Msg, body), nil
+}
+
+func init() {
+	Reply(`^roll(?: (?P<count>\d*)d(?P<sides>\d+))?$`, "roll", Roll,
+		Usage("roll [<count>d<sides>]", "roll some dice, showing how anyone can check the result"))
+}
@@ -0,0 +1,24 @@
+package modules
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestRoll(t *testing.T) {
+	conv := startConversation(t)
+	alice := conv.User("alice")
+
+	alice.Tells("roll 3d6")
+	msg := conv.Expect("^:game_die: 3d6: [1-6] \\+ [1-6] \\+ [1-6] = \\*\\d+\\* \\(check with `roll 3d6 --seed -?\\d+`\\)$")
+
+	check := regexp.MustCompile("`(roll 3d6 --seed -?\\d+)`").FindStringSubmatch(msg.Body)[1]
+	alice.Tells(check)
+	conv.Expect(`^` + regexp.QuoteMeta(msg.Body) + `$`)
+
+	alice.Tells("roll")
+	conv.Expect("^:game_die: 1d6: [1-6] ")
+
+	alice.Tells("roll 0d6")
+	conv.Expect(`I can only roll`)
+}